@@ -0,0 +1,114 @@
+// Copyright 2021 ghstats Project Authors. Licensed under MIT.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/overvenus/ghstats/pkg/config"
+	"github.com/robfig/cron/v3"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(newDaemonCommand())
+}
+
+// newDaemonCommand returns DAEMON command
+func newDaemonCommand() *cobra.Command {
+	command := &cobra.Command{
+		Use:   "daemon",
+		Short: "Run scheduled commands ⏰",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfgPath, err := cmd.Flags().GetString("config")
+			if err != nil {
+				return err
+			}
+			cfg, err := config.ReadConfig(cfgPath)
+			if err != nil {
+				return err
+			}
+			if len(cfg.Schedules) == 0 {
+				return errors.New("no schedules in the config")
+			}
+			exe, err := os.Executable()
+			if err != nil {
+				return err
+			}
+			state, err := loadDaemonState(cfg.Daemon.StateFile)
+			if err != nil {
+				return err
+			}
+			c, missed, err := newDaemon(cfg.Schedules, cfgPath, exe, state, time.Now())
+			if err != nil {
+				return err
+			}
+
+			c.Start()
+			// Catch-up runs are not run by the cron, so that they are
+			// waited on shutdown as well.
+			catchUp := sync.WaitGroup{}
+			for _, job := range missed {
+				catchUp.Add(1)
+				go func(job cron.Job) {
+					defer catchUp.Done()
+					job.Run()
+				}(job)
+			}
+			log.Infof("daemon started with %d schedules", len(cfg.Schedules))
+
+			sig := make(chan os.Signal, 1)
+			signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+			log.Infof("received %s, wait for running commands", <-sig)
+			<-c.Stop().Done()
+			catchUp.Wait()
+			return nil
+		},
+	}
+
+	command.AddCommand(&cobra.Command{
+		Use:   "status",
+		Short: "Show last runs of scheduled commands ⏰",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfgPath, err := cmd.Flags().GetString("config")
+			if err != nil {
+				return err
+			}
+			cfg, err := config.ReadConfig(cfgPath)
+			if err != nil {
+				return err
+			}
+			state, err := loadDaemonState(cfg.Daemon.StateFile)
+			if err != nil {
+				return err
+			}
+			formatTime := func(t *time.Time) string {
+				if t == nil || t.IsZero() {
+					return "-"
+				}
+				return t.Format(time.RFC3339)
+			}
+			tw := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+			fmt.Fprintln(tw, "NAME\tCRON\tLAST RUN\tLAST SUCCESS\tLAST FAILURE\tDURATION\tERROR")
+			for _, sched := range cfg.Schedules {
+				if len(sched.Name) == 0 {
+					sched.Name = sched.Command
+				}
+				js, _ := state.get(sched.Name)
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", sched.Name, sched.Cron,
+					formatTime(&js.LastRun), formatTime(js.LastSuccess), formatTime(js.LastFailure),
+					js.LastDuration, js.LastError)
+			}
+			return tw.Flush()
+		},
+	})
+	return command
+}
@@ -0,0 +1,353 @@
+// Copyright 2021 ghstats Project Authors. Licensed under MIT.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v35/github"
+	"github.com/overvenus/ghstats/pkg/config"
+	"github.com/overvenus/ghstats/pkg/gh"
+	"github.com/overvenus/ghstats/pkg/notify"
+	"github.com/overvenus/ghstats/pkg/report"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// newReviewLatencyCommand returns REVIEW LATENCY command
+func newReviewLatencyCommand() *cobra.Command {
+	command := &cobra.Command{
+		Use:   "latency",
+		Short: "Collect review turnaround of PRs ⏱️",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			days, err := cmd.Flags().GetInt("days")
+			if err != nil {
+				return err
+			}
+			top, err := cmd.Flags().GetInt("top")
+			if err != nil {
+				return err
+			}
+			today := time.Now().In(timeZone)
+			start := today.Add(-time.Duration(days) * 24 * time.Hour)
+			return reviewLatency(cmd, start, today, top)
+		},
+	}
+	command.Flags().Int("days", 7, "Collect PRs that are ready for review within the past days")
+	command.Flags().Int("top", 5, "How many slowest PRs are listed")
+	return command
+}
+
+// prLatency is the review turnaround of a PR, durations are measured
+// from the time it is ready for review, and are negative if the PR has
+// not reached the stage yet.
+type prLatency struct {
+	repo         string
+	issue        *github.Issue
+	readyAt      time.Time
+	firstReview  time.Duration
+	firstApprove time.Duration
+	merge        time.Duration
+	// The first review of each reviewer.
+	reviewers map[string]time.Duration
+}
+
+func reviewLatency(cmd *cobra.Command, start, end time.Time, top int) error {
+	cfgPath, err := cmd.Flags().GetString("config")
+	if err != nil {
+		return err
+	}
+	cfg1, err := config.ReadConfig(cfgPath)
+	if err != nil {
+		return err
+	}
+	cfg := cfg1.Review
+	out, err := getOutput(cmd)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	fetcher, closeFetcher, err := newFetcher(ctx, cmd, cfg1, cfg.GithubToken)
+	if err != nil {
+		return err
+	}
+	defer closeFetcher()
+
+	r, err := latencyReport(ctx, fetcher, cfg, start, end, top)
+	if err != nil {
+		return err
+	}
+	if out.detailed() {
+		return out.write(r)
+	}
+	notifier, err := newReviewNotifier(cfg1)
+	if err != nil {
+		return err
+	}
+	return notifier.Notify(ctx, notify.Message{Report: r, Severity: notify.SeverityInfo})
+}
+
+// latencyReport returns review turnaround of PRs that are ready for review
+// within [start, end).
+func latencyReport(
+	ctx context.Context, fetcher gh.Fetcher, cfg config.Review, start, end time.Time, top int,
+) (*report.Report, error) {
+	c := newReviewConfig(cfg, start, end)
+
+	// PRs ready for review within the range must be updated since then.
+	updateRange := fmt.Sprintf(" updated:%s..%s", start.Format(time.RFC3339), end.Format(time.RFC3339))
+	fmt.Fprintf(os.Stderr, "[%s] Latency -%s\n", time.Now().Format(time.RFC3339), updateRange)
+	prs := make([]*github.Issue, 0)
+	seen := make(map[string]bool)
+	for _, proj := range cfg.Repos {
+		repoLabels := labelFilter{allow: proj.AllowLabels, block: proj.BlockLabels}
+		for _, query := range proj.PRQuery {
+			query = strings.TrimSpace(query) + updateRange
+			log.Info("query: ", query)
+			results, err := fetcher.SearchIssues(ctx, query)
+			if err != nil {
+				return nil, err
+			}
+			for _, res := range results {
+				for _, issue := range res.Issues {
+					if !issue.IsPullRequest() || seen[issue.GetHTMLURL()] {
+						continue
+					}
+					if c.labels.isBlocked(issue.Labels) || repoLabels.isBlocked(issue.Labels) {
+						continue
+					}
+					seen[issue.GetHTMLURL()] = true
+					prs = append(prs, issue)
+				}
+			}
+		}
+	}
+
+	latencies := make([]*prLatency, len(prs))
+	err := parallel(ctx, c.concurrency, len(prs), func(ctx context.Context, i int) error {
+		l, err := collectPRLatency(ctx, c, fetcher, prs[i])
+		latencies[i] = l
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	ready := latencies[:0]
+	for _, l := range latencies {
+		if l != nil && c.withinTimeRange(l.readyAt) {
+			ready = append(ready, l)
+		}
+	}
+	return &report.Report{
+		Title:    "Review Latency ⏱️",
+		Sections: latencySections(ready, end, top),
+		Empty:    "No PRs 😢",
+		Start:    start,
+		End:      end,
+	}, nil
+}
+
+// collectPRLatency measures the review turnaround of a PR, it returns nil
+// if the PR is a draft. Reviews and LGTM comments of blocked users and bots
+// are ignored.
+func collectPRLatency(
+	ctx context.Context, c *reviewConfig, fetcher gh.Fetcher, pr *github.Issue,
+) (*prLatency, error) {
+	owner, repo := gh.GetRepository(pr)
+	number := pr.GetNumber()
+	// Drafts are not ready for review, search results do not tell them.
+	p, err := fetcher.PullRequestsGet(ctx, owner, repo, number)
+	if err != nil {
+		return nil, err
+	}
+	if p.GetDraft() {
+		return nil, nil
+	}
+	l := &prLatency{
+		repo:         fmt.Sprintf("%s/%s", owner, repo),
+		issue:        pr,
+		readyAt:      pr.GetCreatedAt(),
+		firstReview:  -1,
+		firstApprove: -1,
+		merge:        -1,
+		reviewers:    make(map[string]time.Duration),
+	}
+	events, err := fetcher.IssuesListIssueEvents(ctx, owner, repo, number)
+	if err != nil {
+		return nil, err
+	}
+	l.readyAt = lastReadyForReview(l.readyAt, events)
+	for _, event := range events {
+		if event.GetEvent() == "merged" {
+			l.merge = sinceReady(l.readyAt, event.GetCreatedAt())
+		}
+	}
+
+	isReviewer := func(login string) bool {
+		return login != pr.GetUser().GetLogin() &&
+			!c.isUserBlocked(login) && !strings.HasSuffix(login, "[bot]")
+	}
+	record := func(login string, ts time.Time, approve bool) {
+		d := sinceReady(l.readyAt, ts)
+		if l.firstReview < 0 || d < l.firstReview {
+			l.firstReview = d
+		}
+		if approve && (l.firstApprove < 0 || d < l.firstApprove) {
+			l.firstApprove = d
+		}
+		if first, ok := l.reviewers[login]; !ok || d < first {
+			l.reviewers[login] = d
+		}
+	}
+	reviews, err := fetcher.PullRequestsListReviews(ctx, owner, repo, number)
+	if err != nil {
+		return nil, err
+	}
+	for _, review := range reviews {
+		login := review.GetUser().GetLogin()
+		if review.SubmittedAt == nil || !isReviewer(login) {
+			continue
+		}
+		approve := review.GetState() == "APPROVED" || c.isCommentLGTM(review.GetBody())
+		record(login, *review.SubmittedAt, approve)
+	}
+	comments, err := fetcher.IssuesListComments(ctx, owner, repo, number, nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, comment := range comments {
+		login := comment.GetUser().GetLogin()
+		if !isReviewer(login) || !c.isCommentLGTM(comment.GetBody()) {
+			continue
+		}
+		record(login, comment.GetCreatedAt(), true)
+	}
+	return l, nil
+}
+
+// sinceReady returns ts - readyAt, reviews before ready count as zero.
+func sinceReady(readyAt, ts time.Time) time.Duration {
+	if ts.Before(readyAt) {
+		return 0
+	}
+	return ts.Sub(readyAt)
+}
+
+func latencySections(latencies []*prLatency, now time.Time, top int) []report.Section {
+	if len(latencies) == 0 {
+		return nil
+	}
+
+	byRepo := make(map[string][]*prLatency)
+	repos := make([]string, 0)
+	for _, l := range latencies {
+		if _, ok := byRepo[l.repo]; !ok {
+			repos = append(repos, l.repo)
+		}
+		byRepo[l.repo] = append(byRepo[l.repo], l)
+	}
+	sort.Strings(repos)
+	repoSection := report.Section{Title: "Repos"}
+	for _, repo := range repos {
+		ls := byRepo[repo]
+		var reviews, approves, merges []time.Duration
+		waiting := 0
+		for _, l := range ls {
+			if l.firstReview < 0 {
+				waiting++
+			} else {
+				reviews = append(reviews, l.firstReview)
+			}
+			if l.firstApprove >= 0 {
+				approves = append(approves, l.firstApprove)
+			}
+			if l.merge >= 0 {
+				merges = append(merges, l.merge)
+			}
+		}
+		metrics := []report.Metric{report.Count("PRs", len(ls)), report.Count("waiting for review", waiting)}
+		metrics = append(metrics, percentiles("first review", reviews)...)
+		metrics = append(metrics, percentiles("first approval", approves)...)
+		metrics = append(metrics, percentiles("merge", merges)...)
+		repoSection.Rows = append(repoSection.Rows, report.Row{Name: repo, Metrics: metrics})
+	}
+
+	byReviewer := make(map[string][]time.Duration)
+	for _, l := range latencies {
+		for reviewer, d := range l.reviewers {
+			byReviewer[reviewer] = append(byReviewer[reviewer], d)
+		}
+	}
+	reviewers := make([]string, 0, len(byReviewer))
+	for reviewer := range byReviewer {
+		reviewers = append(reviewers, reviewer)
+	}
+	sort.Strings(reviewers)
+	reviewerSection := report.Section{Title: "Reviewers"}
+	for _, reviewer := range reviewers {
+		ds := byReviewer[reviewer]
+		metrics := []report.Metric{report.Count("PRs", len(ds))}
+		metrics = append(metrics, percentiles("first review", ds)...)
+		reviewerSection.Rows = append(reviewerSection.Rows, report.Row{Name: reviewer, Metrics: metrics})
+	}
+
+	// PRs still waiting are as slow as their age.
+	waitFor := func(l *prLatency) time.Duration {
+		if l.firstReview >= 0 {
+			return l.firstReview
+		}
+		return sinceReady(l.readyAt, now)
+	}
+	slowest := make([]*prLatency, len(latencies))
+	copy(slowest, latencies)
+	sort.Slice(slowest, func(i, j int) bool {
+		return waitFor(slowest[i]) > waitFor(slowest[j])
+	})
+	if len(slowest) > top {
+		slowest = slowest[:top]
+	}
+	slowestSection := report.Section{Title: "Slowest first reviews"}
+	for _, l := range slowest {
+		row := report.Row{
+			Ref:     fmt.Sprintf("%s#%d", l.repo, l.issue.GetNumber()),
+			URL:     l.issue.GetHTMLURL(),
+			Title:   l.issue.GetTitle(),
+			Metrics: []report.Metric{report.Duration("wait", waitFor(l))},
+		}
+		if l.firstReview < 0 {
+			row.Note = "waiting"
+		}
+		slowestSection.Rows = append(slowestSection.Rows, row)
+	}
+	return []report.Section{repoSection, reviewerSection, slowestSection}
+}
+
+// percentiles returns p50, p90 and max of durations, it is empty if there
+// is no duration.
+func percentiles(name string, ds []time.Duration) []report.Metric {
+	if len(ds) == 0 {
+		return nil
+	}
+	sorted := make([]time.Duration, len(ds))
+	copy(sorted, ds)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return []report.Metric{
+		report.Duration(name+" p50", percentile(sorted, 50)),
+		report.Duration(name+" p90", percentile(sorted, 90)),
+		report.Duration(name+" max", sorted[len(sorted)-1]),
+	}
+}
+
+// percentile returns the nearest-rank percentile of sorted durations.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
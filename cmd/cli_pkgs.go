@@ -4,16 +4,16 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"os"
 	"strings"
 	"time"
 
 	"github.com/google/go-github/v35/github"
 	"github.com/overvenus/ghstats/pkg/config"
-	"github.com/overvenus/ghstats/pkg/feishu"
 	"github.com/overvenus/ghstats/pkg/gh"
-	"github.com/overvenus/ghstats/pkg/markdown"
+	"github.com/overvenus/ghstats/pkg/notify"
+	"github.com/overvenus/ghstats/pkg/report"
 	"github.com/spf13/cobra"
-	"golang.org/x/oauth2"
 )
 
 func init() {
@@ -33,31 +33,19 @@ func newPkgsCommand() *cobra.Command {
 		Short: "Collect daily PRs for these pkgs ❤️",
 		RunE: func(cmd *cobra.Command, args []string) error {
 			today := time.Now().In(timeZone)
-			lastDay := today
-			switch today.Weekday() {
-			// Monday, collect past 3 days review activity.
-			case time.Monday:
-				lastDay = lastDay.Add(-3 * 24 * time.Hour)
-			// Others, collect past 1 day review activity.
-			default:
-				lastDay = lastDay.Add(-24 * time.Hour)
-			}
-
-			return getPRs(cmd, DailyKind, lastDay, today)
+			return getPRs(cmd, DailyKind, pkgsStart(DailyKind, today), today)
 		},
 	}
 
+	addFromStoreFlag(command)
+	addOutputFlags(command)
+
 	command.AddCommand(&cobra.Command{
 		Use:   "weekly",
 		Short: "Collect weekly PRs for these pkgs ❤️",
 		RunE: func(cmd *cobra.Command, args []string) error {
 			today := time.Now().In(timeZone)
-			firstDayThisWeek := time.Date(
-				today.Year(), today.Month(), today.Day()-7,
-				today.Hour(), today.Minute(), today.Second(), 0, today.Location(),
-			)
-			// [UTC+8 now.hour:now.min on Monday this week, now]
-			return getPRs(cmd, WeeklyKind, firstDayThisWeek, today)
+			return getPRs(cmd, WeeklyKind, pkgsStart(WeeklyKind, today), today)
 		},
 	})
 
@@ -66,18 +54,32 @@ func newPkgsCommand() *cobra.Command {
 		Short: "Collect monthly PRs for these pkgs ❤️",
 		RunE: func(cmd *cobra.Command, args []string) error {
 			today := time.Now().In(timeZone)
-			firstDayLastMonth := time.Date(
-				today.Year(), today.Month()-1, today.Day(),
-				today.Hour(), today.Minute(), today.Second(), 0, today.Location(),
-			)
-			// [UTC+8 now.hour:now.min on the first day of last month, now]
-			return getPRs(cmd, MonthlyKind, firstDayLastMonth, today)
+			return getPRs(cmd, MonthlyKind, pkgsStart(MonthlyKind, today), today)
 		},
 	})
 
 	return command
 }
 
+// pkgsStart returns the start of the period of kind that ends at today.
+func pkgsStart(kind string, today time.Time) time.Time {
+	switch kind {
+	case WeeklyKind:
+		// [UTC+8 now.hour:now.min on Monday this week, now]
+		return time.Date(
+			today.Year(), today.Month(), today.Day()-7,
+			today.Hour(), today.Minute(), today.Second(), 0, today.Location(),
+		)
+	case MonthlyKind:
+		// [UTC+8 now.hour:now.min on the first day of last month, now]
+		return time.Date(
+			today.Year(), today.Month()-1, today.Day(),
+			today.Hour(), today.Minute(), today.Second(), 0, today.Location(),
+		)
+	}
+	return reviewStart(DailyKind, today)
+}
+
 func getPRs(cmd *cobra.Command, kind string, start, end time.Time) error {
 	cfgPath, err := cmd.Flags().GetString("config")
 	if err != nil {
@@ -88,12 +90,44 @@ func getPRs(cmd *cobra.Command, kind string, start, end time.Time) error {
 		return err
 	}
 	cfg := cfg1.PTAL
+	out, err := getOutput(cmd)
+	if err != nil {
+		return err
+	}
 	ctx := context.Background()
-	client := github.NewClient(oauth2.NewClient(ctx, oauth2.StaticTokenSource(
-		&oauth2.Token{AccessToken: cfg.GithubToken},
-	)))
+	fetcher, closeFetcher, err := newFetcher(ctx, cmd, cfg1, cfg.GithubToken)
+	if err != nil {
+		return err
+	}
+	defer closeFetcher()
+
+	r, err := pkgsReport(ctx, fetcher, cfg, kind, start, end)
+	if err != nil {
+		return err
+	}
+	if out.detailed() {
+		return out.write(r)
+	}
+	if r.IsEmpty() {
+		// Good! No PR need to be reviewed.
+		fmt.Println("No PR need to be reviewed.")
+		return nil
+	}
+	notifier, err := newPTALNotifier(cfg1)
+	if err != nil {
+		return err
+	}
+	return notifier.Notify(ctx, notify.Message{Report: r, Severity: notify.SeverityInfo})
+}
+
+// pkgsReport returns PRs created within [start, end) that change the
+// configured packages, grouped by repos. Rows mention requested reviewers
+// and owners of the changed packages.
+func pkgsReport(
+	ctx context.Context, fetcher gh.Fetcher, cfg config.PTAL, kind string, start, end time.Time,
+) (*report.Report, error) {
 	pInfo := ptalInfo{startTimestamp: start, endTimestamp: end}
-	fmt.Printf("[repos: %s] PRs %s %s - %s\n", cfg.ReposName(), kind, start.Format(time.RFC3339), end.Format(time.RFC3339))
+	fmt.Fprintf(os.Stderr, "[repos: %s] PRs %s %s - %s\n", cfg.ReposName(), kind, start.Format(time.RFC3339), end.Format(time.RFC3339))
 
 	maxPages := 5
 	switch kind {
@@ -105,28 +139,59 @@ func getPRs(cmd *cobra.Command, kind string, start, end time.Time) error {
 		maxPages = 20
 	}
 
-	buf := strings.Builder{}
+	r := &report.Report{
+		Title: fmt.Sprintf("%s PTAL Repos:[%v] ❤️ - %s", cfg.ReportName, cfg.ReposName(), kind),
+		Empty: "No PR need to be reviewed 🎉",
+		Start: start,
+		End:   end,
+	}
+	byRepo := make([]report.Point, 0, len(cfg.Repos))
+	daily := make(map[string]int)
 	for _, proj := range cfg.Repos {
 		repoInfs := strings.SplitN(proj.PROwnerRepo, "/", 2)
 		if len(repoInfs) != 2 {
-			return errors.New(fmt.Sprintf("repo str:%v, split strings:%v", proj.PROwnerRepo, repoInfs))
+			return nil, errors.New(fmt.Sprintf("repo str:%v, split strings:%v", proj.PROwnerRepo, repoInfs))
 		}
 
-		results, err := gh.PullRequestsList(ctx, client, repoInfs[0], repoInfs[1], maxPages)
+		results, err := fetcher.PullRequestsList(ctx, repoInfs[0], repoInfs[1], maxPages)
+		if err != nil {
+			return nil, err
+		}
+		prs, err := filterPR(fetcher, pInfo, proj, results)
 		if err != nil {
-			return err
+			return nil, err
+		}
+		section := report.Section{Title: proj.Name}
+		for _, pr := range prs {
+			mentions, err := pkgsMentions(fetcher, proj, pr)
+			if err != nil {
+				return nil, err
+			}
+			section.Rows = append(section.Rows, report.Row{
+				Ref:      fmt.Sprintf("#%d", *pr.Number),
+				URL:      *pr.HTMLURL,
+				Title:    *pr.Title,
+				Author:   pr.GetUser().GetLogin(),
+				Mentions: mentions,
+			})
+			daily[pr.GetCreatedAt().In(timeZone).Format("01-02")]++
 		}
-		filterPR(client, pInfo, proj, results, &buf)
+		r.Sections = append(r.Sections, section)
+		byRepo = append(byRepo, report.Point{Label: proj.Name, Value: float64(len(prs))})
 	}
 
-	if buf.Len() == 0 {
-		// Good! No PR need to be reviewed.
-		fmt.Println("No PR need to be reviewed.")
-		return nil
+	timeline := make([]report.Point, 0)
+	first := start.In(timeZone)
+	first = time.Date(first.Year(), first.Month(), first.Day(), 0, 0, 0, 0, timeZone)
+	for day := first; day.Before(end); day = day.AddDate(0, 0, 1) {
+		label := day.Format("01-02")
+		timeline = append(timeline, report.Point{Label: label, Value: float64(daily[label])})
+	}
+	r.Charts = []report.Chart{
+		{Title: "PRs by repo", Kind: report.ChartBar, Points: byRepo},
+		{Title: "PRs by day", Kind: report.ChartTimeline, Points: timeline},
 	}
-	bot := feishu.WebhookBot{Token: cfg.FeishuWebhookToken, IsTest: cfg1.IsOnlyPrintMsg}
-	return bot.SendMarkdownMessage(ctx, fmt.Sprintf("%s PTAL Repos:[%v] ❤️ - %s", cfg.ReportName, cfg.ReposName(), kind),
-		buf.String(), feishu.TitleColorWathet)
+	return r, nil
 }
 
 type ptalInfo struct {
@@ -140,61 +205,133 @@ func (c *ptalInfo) withinTimeRange(ts time.Time) bool {
 	return (ts.After(c.startTimestamp) || ts.Equal(c.startTimestamp)) && ts.Before(c.endTimestamp)
 }
 
-func isInPackages(client *github.Client, packages []string, pr *github.PullRequest) (bool, error) {
+func isInPackages(fetcher gh.Fetcher, packages []string, pr *github.PullRequest) (bool, error) {
+	matched, err := matchedPackages(fetcher, packages, pr)
+	if err != nil {
+		return false, err
+	}
+	return len(matched) != 0, nil
+}
+
+// matchedPackages returns packages that are changed by the PR.
+func matchedPackages(fetcher gh.Fetcher, packages []string, pr *github.PullRequest) ([]string, error) {
 	if len(packages) == 0 {
-		return false, nil
+		return nil, nil
 	}
 
 	owner, repo := gh.GetPRRepository(pr)
 	number := pr.GetNumber()
-	prFiles, err := gh.PullRequestsListFiles(context.Background(), client, owner, repo, number)
+	prFiles, err := fetcher.PullRequestsListFiles(context.Background(), owner, repo, number)
 	if err != nil {
-		return false, err
+		return nil, err
 	}
-	for _, file := range prFiles {
-		for _, pkg := range packages {
-			if strings.Contains(*file.Filename, pkg) {
-				return true, nil
+	matched := make([]string, 0)
+	for _, pkg := range packages {
+		for _, file := range prFiles {
+			if strings.Contains(file.GetFilename(), pkg) {
+				matched = append(matched, pkg)
+				break
 			}
 		}
 	}
-	return false, nil
+	return matched, nil
 }
 
-func filterPR(client *github.Client, pInfo ptalInfo, repo config.Repo,
-	projectPRs []*github.PullRequest, buf *strings.Builder) error {
-	prs := strings.Builder{}
+// pkgsMentions returns requested reviewers of the PR and owners of the
+// packages it changes, without duplicates.
+func pkgsMentions(fetcher gh.Fetcher, repo config.Repo, pr *github.PullRequest) ([]string, error) {
+	mentions := requestedReviewers(pr)
+	if len(repo.PackageOwners) == 0 {
+		return mentions, nil
+	}
+	matched, err := matchedPackages(fetcher, repo.Packages, pr)
+	if err != nil {
+		return nil, err
+	}
+	for _, pkg := range matched {
+		mentions = appendLogins(mentions, repo.PackageOwners[pkg]...)
+	}
+	return mentions, nil
+}
+
+// removeLogins returns list without logins, logins are case insensitive.
+func removeLogins(list []string, logins ...string) []string {
+	kept := make([]string, 0, len(list))
+	for _, l := range list {
+		found := false
+		for _, login := range logins {
+			if strings.EqualFold(l, login) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			kept = append(kept, l)
+		}
+	}
+	return kept
+}
+
+// appendLogins appends logins that are not in list yet, logins are case
+// insensitive.
+func appendLogins(list []string, logins ...string) []string {
+	for _, login := range logins {
+		found := false
+		for _, l := range list {
+			if strings.EqualFold(l, login) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			list = append(list, login)
+		}
+	}
+	return list
+}
+
+func filterPR(fetcher gh.Fetcher, pInfo ptalInfo, repo config.Repo,
+	projectPRs []*github.PullRequest) ([]*github.PullRequest, error) {
+	prs := make([]*github.PullRequest, 0)
 	for _, pr := range projectPRs {
 		// filter out PR creation time beyond [start, end) range
 		if !pInfo.withinTimeRange(*pr.CreatedAt) {
-			fmt.Printf("repo:%s filter PR created time:%s, url:%s, title:%s \n",
+			fmt.Fprintf(os.Stderr, "repo:%s filter PR created time:%s, url:%s, title:%s \n",
 				repo.Name, *pr.CreatedAt, pr.GetHTMLURL(), pr.GetTitle())
 			continue
 		}
-		// filter PR created by ti-chi-bot
-		if strings.Contains(*pr.User.Login, "ti-chi-bot") {
-			fmt.Printf("repo:%s filter creates PR by bot, url:%s, title:%s \n", repo.Name, pr.GetHTMLURL(), pr.GetTitle())
-			continue
-		}
-		// filter out the cfg.packages
-		isContainPkg, err := isInPackages(client, repo.Packages, pr)
+		reason, err := pkgsSkipReason(fetcher, repo, pr)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		if !isContainPkg {
-			fmt.Printf("repo:%s filter doesn't contain pkgs:%s, url:%s, title:%s \n",
-				repo.Name, repo.Packages, pr.GetHTMLURL(), pr.GetTitle())
+		if len(reason) != 0 {
+			fmt.Fprintf(os.Stderr, "repo:%s %s, url:%s, title:%s \n", repo.Name, reason, pr.GetHTMLURL(), pr.GetTitle())
 			continue
 		}
 
-		prs.WriteString(fmt.Sprintf("%s %s\n",
-			markdown.Link(fmt.Sprintf("#%d", *pr.Number), *pr.HTMLURL),
-			markdown.Escape(*pr.Title),
-		))
+		prs = append(prs, pr)
+	}
+	return prs, nil
+}
+
+// pkgsSkipReason returns why the PR is not sent to owners of packages of
+// the repo, or "" if it is sent.
+func pkgsSkipReason(fetcher gh.Fetcher, repo config.Repo, pr *github.PullRequest) (string, error) {
+	// filter out PRs by the repo labels
+	if (labelFilter{allow: repo.AllowLabels, block: repo.BlockLabels}).isBlocked(pr.Labels) {
+		return "filter by labels", nil
+	}
+	// filter PR created by ti-chi-bot
+	if strings.Contains(pr.GetUser().GetLogin(), "ti-chi-bot") {
+		return "filter creates PR by bot", nil
+	}
+	// filter out the cfg.packages
+	isContainPkg, err := isInPackages(fetcher, repo.Packages, pr)
+	if err != nil {
+		return "", err
 	}
-	if prs.Len() != 0 {
-		buf.WriteString(fmt.Sprintf("## %s\n", markdown.Escape(repo.Name)))
-		buf.WriteString(prs.String())
+	if !isContainPkg {
+		return fmt.Sprintf("filter doesn't contain pkgs:%s", repo.Packages), nil
 	}
-	return nil
+	return "", nil
 }
@@ -5,15 +5,17 @@ package cmd
 import (
 	"context"
 	"fmt"
-	"strings"
+	"net/url"
+	"os"
+	"sort"
+	"time"
 
 	"github.com/google/go-github/v35/github"
 	"github.com/overvenus/ghstats/pkg/config"
-	"github.com/overvenus/ghstats/pkg/feishu"
 	"github.com/overvenus/ghstats/pkg/gh"
-	"github.com/overvenus/ghstats/pkg/markdown"
+	"github.com/overvenus/ghstats/pkg/notify"
+	"github.com/overvenus/ghstats/pkg/report"
 	"github.com/spf13/cobra"
-	"golang.org/x/oauth2"
 )
 
 func init() {
@@ -36,58 +38,275 @@ func newPTALCommand() *cobra.Command {
 			}
 			cfg := cfg1.PTAL
 			ctx := context.Background()
-			client := github.NewClient(oauth2.NewClient(ctx, oauth2.StaticTokenSource(
-				&oauth2.Token{AccessToken: cfg.GithubToken},
-			)))
-
-			projects := make(map[string][]*github.IssuesSearchResult)
-			for _, proj := range cfg.Repos {
-				for _, query := range proj.PRQuery {
-					results, err := gh.SearchIssues(ctx, client, query)
-					if err != nil {
-						return err
-					}
-					projects[proj.Name] = append(projects[proj.Name], results...)
-				}
+			fetcher, err := newGithubFetcher(ctx, cfg1.GitHub, cfg.GithubToken)
+			if err != nil {
+				return err
 			}
-			buf := strings.Builder{}
-			// To keep message short, we only keep the most recent 5 PRs.
-			max := 5
-			count := 0
-			for repo, results := range projects {
-				prs := strings.Builder{}
-				for _, res := range results {
-					for _, issue := range res.Issues {
-						if count > max {
-							break
-						}
-						// do not find a unify label to identify "WIP" status, so just check the title for now
-						if strings.Contains(strings.ToLower(*issue.Title), "wip") {
-							continue
-						}
-						// So as to "DNM"
-						if strings.Contains(strings.ToLower(*issue.Title), "dnm") {
-							continue
-						}
-						prs.WriteString(fmt.Sprintf("%s %s\n",
-							markdown.Link(fmt.Sprintf("#%d", *issue.Number), *issue.HTMLURL),
-							markdown.Escape(*issue.Title),
-						))
-					}
-					count++
-				}
-				if prs.Len() != 0 {
-					buf.WriteString(fmt.Sprintf("## %s\n", markdown.Escape(repo)))
-					buf.WriteString(prs.String())
-				}
+
+			filter, err := newPTALFilter(cfg)
+			if err != nil {
+				return err
 			}
-			if buf.Len() == 0 {
+			r, severity, err := ptalFullReport(ctx, fetcher, cfg, filter)
+			if err != nil {
+				return err
+			}
+			explain, err := cmd.Flags().GetBool("explain")
+			if err != nil {
+				return err
+			}
+			if explain {
+				filter.explain(os.Stderr)
+			}
+			if r.IsEmpty() {
 				// Good! No PR need to be reviewed.
 				return nil
 			}
-			bot := feishu.WebhookBot{Token: cfg.FeishuWebhookToken, IsTest: cfg1.IsOnlyPrintMsg}
-			return bot.SendMarkdownMessage(ctx, "PTAL ❤️", buf.String(), feishu.TitleColorWathet)
+			dm, err := cmd.Flags().GetBool("dm")
+			if err != nil {
+				return err
+			}
+			if dm {
+				// Reviewers hear about all PRs waiting on them.
+				return notifyReviewers(ctx, cfg1, fetcher, r)
+			}
+			notifier, err := newPTALNotifier(cfg1)
+			if err != nil {
+				return err
+			}
+			return notifier.Notify(ctx, notify.Message{Report: limitPTALRows(r), Severity: severity})
 		},
 	}
+	command.Flags().Bool("dm", false,
+		"Send each reviewer a digest of PRs waiting on them, instead of the whole list")
+	command.Flags().Bool("explain", false, "Print skipped PRs and why they are skipped")
 	return command
 }
+
+// ptalConcurrency is how many PRs are fetched concurrently.
+const ptalConcurrency = 4
+
+// ptalMaxRows is how many PRs of each repo are kept in a broadcast report,
+// to keep messages short.
+const ptalMaxRows = 5
+
+// ptalReport returns ptalFullReport with the oldest ptalMaxRows PRs of
+// each repo.
+func ptalReport(
+	ctx context.Context, fetcher gh.Fetcher, cfg config.PTAL, filter *ptalFilter,
+) (*report.Report, notify.Severity, error) {
+	r, severity, err := ptalFullReport(ctx, fetcher, cfg, filter)
+	if err != nil {
+		return nil, severity, err
+	}
+	return limitPTALRows(r), severity, nil
+}
+
+// limitPTALRows returns a copy of the report that keeps the first
+// ptalMaxRows rows of each section, to keep messages short.
+func limitPTALRows(r *report.Report) *report.Report {
+	limited := *r
+	limited.Sections = make([]report.Section, 0, len(r.Sections))
+	for _, section := range r.Sections {
+		if len(section.Rows) > ptalMaxRows {
+			section.Rows = section.Rows[:ptalMaxRows]
+		}
+		limited.Sections = append(limited.Sections, section)
+	}
+	return &limited
+}
+
+// ptalFullReport returns all PRs that need to be reviewed, grouped by
+// repos and sorted oldest first. Rows have authors, ages since ready for review, idle times since last
+// activities and sizes, which are changed lines of PRs, and mention
+// requested reviewers. PRs that wait longer than thresholds of
+// cfg.Staleness in working days are noted, and the returned severity is
+// the highest of them. PRs are skipped by filter.
+func ptalFullReport(
+	ctx context.Context, fetcher gh.Fetcher, cfg config.PTAL, filter *ptalFilter,
+) (*report.Report, notify.Severity, error) {
+	projects := make(map[string][]*github.IssuesSearchResult)
+	repoLabels := make(map[string]labelFilter)
+	searches := make(map[string]string)
+	names := make([]string, 0, len(cfg.Repos))
+	for _, proj := range cfg.Repos {
+		if _, ok := repoLabels[proj.Name]; !ok {
+			names = append(names, proj.Name)
+		}
+		repoLabels[proj.Name] = labelFilter{allow: proj.AllowLabels, block: proj.BlockLabels}
+		for _, query := range proj.PRQuery {
+			if _, ok := searches[proj.Name]; !ok {
+				searches[proj.Name] = searchURL(query)
+			}
+			results, err := fetcher.SearchIssues(ctx, query)
+			if err != nil {
+				return nil, notify.SeverityInfo, err
+			}
+			projects[proj.Name] = append(projects[proj.Name], results...)
+		}
+	}
+	r := &report.Report{Title: "PTAL ❤️", Empty: "No PR need to be reviewed 🎉"}
+	now := time.Now()
+	// Issues of rows of each section.
+	issues := make([][]*github.Issue, 0, len(names))
+	for _, repo := range names {
+		section := report.Section{Title: repo, URL: searches[repo]}
+		sectionIssues := make([]*github.Issue, 0)
+		// Queries of a repo may overlap, and may return issues.
+		seen := make(map[string]bool)
+		for _, res := range projects[repo] {
+			for _, issue := range res.Issues {
+				if !issue.IsPullRequest() || seen[issue.GetHTMLURL()] || filter.skipIssue(repoLabels[repo], issue) {
+					continue
+				}
+				seen[issue.GetHTMLURL()] = true
+				section.Rows = append(section.Rows, report.Row{
+					Ref:    fmt.Sprintf("#%d", *issue.Number),
+					URL:    *issue.HTMLURL,
+					Title:  *issue.Title,
+					Author: issue.GetUser().GetLogin(),
+				})
+				sectionIssues = append(sectionIssues, issue)
+			}
+		}
+		r.Sections = append(r.Sections, section)
+		issues = append(issues, sectionIssues)
+	}
+
+	// Search results have neither draft states, ready times, sizes nor
+	// requested reviewers.
+	rows := make([]*report.Row, 0)
+	rowIssues := make([]*github.Issue, 0)
+	for i := range r.Sections {
+		for j := range r.Sections[i].Rows {
+			rows = append(rows, &r.Sections[i].Rows[j])
+			rowIssues = append(rowIssues, issues[i][j])
+		}
+	}
+	prs := make([]*github.PullRequest, len(rows))
+	waits := make([]time.Duration, len(rows))
+	err := parallel(ctx, ptalConcurrency, len(rows), func(ctx context.Context, i int) error {
+		issue := rowIssues[i]
+		owner, repo := gh.GetRepository(issue)
+		pr, err := fetcher.PullRequestsGet(ctx, owner, repo, issue.GetNumber())
+		if err != nil {
+			return err
+		}
+		prs[i] = pr
+		if pr.GetDraft() {
+			return nil
+		}
+		ready, err := readyForReviewAt(ctx, fetcher, pr)
+		if err != nil {
+			return err
+		}
+		waits[i] = workingDuration(ready, now)
+		rows[i].Metrics = []report.Metric{
+			report.Duration("age", now.Sub(ready)),
+			report.Duration("idle", now.Sub(issue.GetUpdatedAt())),
+			report.Count("size", pr.GetAdditions()+pr.GetDeletions()),
+		}
+		rows[i].Mentions = requestedReviewers(pr)
+		return nil
+	})
+	if err != nil {
+		return nil, notify.SeverityInfo, err
+	}
+
+	severity := notify.SeverityInfo
+	drafts := make(map[string]bool)
+	for i, row := range rows {
+		if filter.skipPR(prs[i]) {
+			drafts[row.URL] = true
+			continue
+		}
+		if s := staleness(cfg.Staleness, row, waits[i]); s > severity {
+			severity = s
+		}
+	}
+	// Drafts are removed and rows are sorted oldest first, so that
+	// limitPTALRows keeps the oldest PRs that need to be reviewed.
+	for i := range r.Sections {
+		kept := make([]report.Row, 0, len(r.Sections[i].Rows))
+		for _, row := range r.Sections[i].Rows {
+			if !drafts[row.URL] {
+				kept = append(kept, row)
+			}
+		}
+		sort.SliceStable(kept, func(i, j int) bool { return rowAge(kept[i]) > rowAge(kept[j]) })
+		r.Sections[i].Rows = kept
+	}
+	return r, severity, nil
+}
+
+// readyForReviewAt returns when the PR was marked ready for review the last
+// time, or when it was created if it has never been a draft.
+func readyForReviewAt(ctx context.Context, fetcher gh.Fetcher, pr *github.PullRequest) (time.Time, error) {
+	owner, repo := gh.GetPRRepository(pr)
+	events, err := fetcher.IssuesListIssueEvents(ctx, owner, repo, pr.GetNumber())
+	if err != nil {
+		return time.Time{}, err
+	}
+	return lastReadyForReview(pr.GetCreatedAt(), events), nil
+}
+
+// lastReadyForReview returns the time of the last ready_for_review event,
+// or created if there is none. A PR that is converted back to draft is
+// ready again when it is marked so again.
+func lastReadyForReview(created time.Time, events []*github.IssueEvent) time.Time {
+	ready := created
+	for _, e := range events {
+		if e.GetEvent() == "ready_for_review" && e.GetCreatedAt().After(ready) {
+			ready = e.GetCreatedAt()
+		}
+	}
+	return ready
+}
+
+// staleness notes the row if the PR waits longer than thresholds, leads
+// are mentioned if it is escalated. It returns the severity of the row.
+func staleness(cfg config.Staleness, row *report.Row, wait time.Duration) notify.Severity {
+	days := wait.Hours() / 24
+	switch {
+	case cfg.EscalateDays > 0 && days >= cfg.EscalateDays:
+		row.Note = fmt.Sprintf("🔥 waiting for %.1f working days", days)
+		row.Mentions = appendLogins(row.Mentions, cfg.Leads...)
+		return notify.SeverityDanger
+	case cfg.WarnDays > 0 && days >= cfg.WarnDays:
+		row.Note = fmt.Sprintf("⚠️ waiting for %.1f working days", days)
+		return notify.SeverityWarning
+	}
+	return notify.SeverityInfo
+}
+
+// workingDuration returns the duration within [from, to) except weekends
+// in timeZone.
+func workingDuration(from, to time.Time) time.Duration {
+	d := time.Duration(0)
+	for t := from.In(timeZone); t.Before(to); {
+		next := time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, timeZone)
+		if next.After(to) {
+			next = to
+		}
+		if wd := t.Weekday(); wd != time.Saturday && wd != time.Sunday {
+			d += next.Sub(t)
+		}
+		t = next
+	}
+	return d
+}
+
+// requestedReviewers returns logins of users who are requested to review
+// the PR.
+func requestedReviewers(pr *github.PullRequest) []string {
+	logins := make([]string, 0, len(pr.RequestedReviewers))
+	for _, u := range pr.RequestedReviewers {
+		logins = append(logins, u.GetLogin())
+	}
+	return logins
+}
+
+// searchURL returns the URL of a GitHub issue search.
+func searchURL(query string) string {
+	return "https://github.com/search?type=issues&q=" + url.QueryEscape(query)
+}
@@ -0,0 +1,101 @@
+// Copyright 2021 ghstats Project Authors. Licensed under MIT.
+
+package cmd
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/overvenus/ghstats/pkg/config"
+	"github.com/overvenus/ghstats/pkg/notify"
+	"github.com/overvenus/ghstats/pkg/report"
+)
+
+func TestWorkingDuration(t *testing.T) {
+	// 2021-06-04 is a Friday.
+	at := func(day, hour int) time.Time {
+		return time.Date(2021, 6, day, hour, 0, 0, 0, timeZone)
+	}
+	cases := []struct {
+		name     string
+		from, to time.Time
+		expect   time.Duration
+	}{
+		{name: "within a day", from: at(7, 9), to: at(7, 17), expect: 8 * time.Hour},
+		{name: "over a weekend", from: at(4, 12), to: at(7, 12), expect: 24 * time.Hour},
+		{name: "over a week", from: at(3, 12), to: at(8, 12), expect: 72 * time.Hour},
+		{name: "starts on saturday", from: at(5, 10), to: at(7, 10), expect: 10 * time.Hour},
+		{name: "within a weekend", from: at(5, 10), to: at(6, 20), expect: 0},
+		{name: "ends on saturday", from: at(4, 20), to: at(5, 20), expect: 4 * time.Hour},
+		{name: "to before from", from: at(7, 12), to: at(7, 9), expect: 0},
+		// Days are split in timeZone, Friday 20:00 in UTC is Saturday 04:00
+		// and Sunday 20:00 is Monday 04:00.
+		{
+			name:   "another time zone",
+			from:   time.Date(2021, 6, 4, 20, 0, 0, 0, time.UTC),
+			to:     time.Date(2021, 6, 6, 20, 0, 0, 0, time.UTC),
+			expect: 4 * time.Hour,
+		},
+	}
+	for _, c := range cases {
+		if d := workingDuration(c.from, c.to); d != c.expect {
+			t.Errorf("%s: expect %s, got %s", c.name, c.expect, d)
+		}
+	}
+}
+
+func TestStaleness(t *testing.T) {
+	day := 24 * time.Hour
+	cfg := config.Staleness{WarnDays: 2, EscalateDays: 5, Leads: []string{"lead"}}
+	cases := []struct {
+		name     string
+		cfg      config.Staleness
+		mentions []string
+		wait     time.Duration
+		severity notify.Severity
+		note     string
+		expect   []string
+	}{
+		{
+			name: "fresh", cfg: cfg, mentions: []string{"alice"}, wait: day,
+			severity: notify.SeverityInfo, expect: []string{"alice"},
+		},
+		{
+			name: "just before warning", cfg: cfg, mentions: []string{"alice"}, wait: 2*day - time.Second,
+			severity: notify.SeverityInfo, expect: []string{"alice"},
+		},
+		{
+			name: "warning", cfg: cfg, mentions: []string{"alice"}, wait: 2 * day,
+			severity: notify.SeverityWarning, note: "⚠️ waiting for 2.0 working days", expect: []string{"alice"},
+		},
+		{
+			name: "just before escalation", cfg: cfg, mentions: []string{"alice"}, wait: 5*day - time.Second,
+			severity: notify.SeverityWarning, note: "⚠️ waiting for 5.0 working days", expect: []string{"alice"},
+		},
+		{
+			name: "escalation", cfg: cfg, mentions: []string{"alice"}, wait: 5 * day,
+			severity: notify.SeverityDanger, note: "🔥 waiting for 5.0 working days", expect: []string{"alice", "lead"},
+		},
+		{
+			name: "leads are not mentioned twice", cfg: cfg, mentions: []string{"Lead"}, wait: 6 * day,
+			severity: notify.SeverityDanger, note: "🔥 waiting for 6.0 working days", expect: []string{"Lead"},
+		},
+		{
+			name: "thresholds are off", cfg: config.Staleness{Leads: []string{"lead"}}, wait: 100 * day,
+			severity: notify.SeverityInfo,
+		},
+	}
+	for _, c := range cases {
+		row := &report.Row{Mentions: c.mentions}
+		if s := staleness(c.cfg, row, c.wait); s != c.severity {
+			t.Errorf("%s: expect severity %d, got %d", c.name, c.severity, s)
+		}
+		if row.Note != c.note {
+			t.Errorf("%s: expect note %q, got %q", c.name, c.note, row.Note)
+		}
+		if !reflect.DeepEqual(row.Mentions, c.expect) {
+			t.Errorf("%s: expect mentions %q, got %q", c.name, c.expect, row.Mentions)
+		}
+	}
+}
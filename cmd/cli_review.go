@@ -5,22 +5,30 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"math"
+	"os"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/go-github/v35/github"
 	"github.com/overvenus/ghstats/pkg/config"
 	"github.com/overvenus/ghstats/pkg/debug"
-	"github.com/overvenus/ghstats/pkg/feishu"
 	"github.com/overvenus/ghstats/pkg/gh"
-	"github.com/overvenus/ghstats/pkg/markdown"
+	"github.com/overvenus/ghstats/pkg/notify"
+	"github.com/overvenus/ghstats/pkg/report"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
-	"golang.org/x/oauth2"
 )
 
 const timeFormat = "2006-01-02 15:04:05"
 
+// Issue event types credited as triage work by default.
+var defaultTriageEvents = []string{
+	"labeled", "unlabeled", "assigned", "milestoned", "closed", "reopened",
+}
+
 var timeZone *time.Location
 
 func init() {
@@ -35,31 +43,19 @@ func newReviewCommand() *cobra.Command {
 		Short: "Collect daily reviews 👍",
 		RunE: func(cmd *cobra.Command, args []string) error {
 			today := time.Now().In(timeZone)
-			lastDay := today
-			switch today.Weekday() {
-			// Monday, collect past 3 days review activity.
-			case time.Monday:
-				lastDay = lastDay.Add(-3 * 24 * time.Hour)
-			// Others, collect past 1 day review activity.
-			default:
-				lastDay = lastDay.Add(-24 * time.Hour)
-			}
-
-			return reviewRange(cmd, "Daily", lastDay, today)
+			return reviewRange(cmd, DailyKind, reviewStart(DailyKind, today), today)
 		},
 	}
 
+	addFromStoreFlag(command)
+	addOutputFlags(command)
+
 	command.AddCommand(&cobra.Command{
 		Use:   "weekly",
 		Short: "Collect weekly reviews 👍",
 		RunE: func(cmd *cobra.Command, args []string) error {
 			today := time.Now().In(timeZone)
-			firstDayThisWeek := time.Date(
-				today.Year(), today.Month(), today.Day()-int(today.Weekday())+1,
-				10, 0, 0, 0, today.Location(),
-			)
-			// [UTC+8 10:00 on Monday this week, now]
-			return reviewRange(cmd, "Weekly", firstDayThisWeek, today)
+			return reviewRange(cmd, WeeklyKind, reviewStart(WeeklyKind, today), today)
 		},
 	})
 
@@ -68,12 +64,7 @@ func newReviewCommand() *cobra.Command {
 		Short: "Collect monthly reviews 👍",
 		RunE: func(cmd *cobra.Command, args []string) error {
 			today := time.Now().In(timeZone)
-			firstDayLastMonth := time.Date(
-				today.Year(), today.Month()-1, today.Day(),
-				10, 0, 0, 0, today.Location(),
-			)
-			// [UTC+8 10:00 on the first day of last month, now]
-			return reviewRange(cmd, "Monthly", firstDayLastMonth, today)
+			return reviewRange(cmd, MonthlyKind, reviewStart(MonthlyKind, today), today)
 		},
 	})
 
@@ -93,9 +84,38 @@ func newReviewCommand() *cobra.Command {
 		},
 	})
 
+	command.AddCommand(newReviewLatencyCommand())
+
 	return command
 }
 
+// reviewStart returns the start of the review period of kind that ends
+// at today.
+func reviewStart(kind string, today time.Time) time.Time {
+	switch kind {
+	case WeeklyKind:
+		// [UTC+8 10:00 on Monday this week, now]
+		return time.Date(
+			today.Year(), today.Month(), today.Day()-int(today.Weekday())+1,
+			10, 0, 0, 0, today.Location(),
+		)
+	case MonthlyKind:
+		// [UTC+8 10:00 on the first day of last month, now]
+		return time.Date(
+			today.Year(), today.Month()-1, today.Day(),
+			10, 0, 0, 0, today.Location(),
+		)
+	}
+	switch today.Weekday() {
+	// Monday, collect past 3 days review activity.
+	case time.Monday:
+		return today.Add(-3 * 24 * time.Hour)
+	// Others, collect past 1 day review activity.
+	default:
+		return today.Add(-24 * time.Hour)
+	}
+}
+
 func reviewRange(cmd *cobra.Command, kind string, start, end time.Time) error {
 	cfgPath, err := cmd.Flags().GetString("config")
 	if err != nil {
@@ -106,97 +126,166 @@ func reviewRange(cmd *cobra.Command, kind string, start, end time.Time) error {
 		return err
 	}
 	cfg := cfg1.Review
-	c := &reviewConfig{
-		lgtmComments:   cfg.LGTMComments,
-		blockComments:  cfg.BlockComments,
-		blockLabels:    cfg.BlockLabels,
-		allowUsers:     make(map[string]bool, len(cfg.AllowUsers)),
-		blockUsers:     make(map[string]bool, len(cfg.BlockUsers)),
-		startTimestamp: start,
-		endTimestamp:   end,
+	out, err := getOutput(cmd)
+	if err != nil {
+		return err
 	}
-	for i := range cfg.AllowUsers {
-		c.allowUsers[cfg.AllowUsers[i]] = true
+	ctx := context.Background()
+	fetcher, closeFetcher, err := newFetcher(ctx, cmd, cfg1, cfg.GithubToken)
+	if err != nil {
+		return err
 	}
-	for i := range cfg.BlockUsers {
-		c.blockUsers[cfg.BlockUsers[i]] = true
+	defer closeFetcher()
+
+	r, err := reviewReport(ctx, fetcher, cfg, kind, start, end, out.detailed())
+	if err != nil {
+		return err
 	}
-	ctx := context.Background()
-	client := github.NewClient(oauth2.NewClient(ctx, oauth2.StaticTokenSource(
-		&oauth2.Token{AccessToken: cfg.GithubToken},
-	)))
+	if out.detailed() {
+		return out.write(r)
+	}
+	notifier, err := newReviewNotifier(cfg1)
+	if err != nil {
+		return err
+	}
+	return notifier.Notify(ctx, notify.Message{Report: r, Severity: notify.SeveritySuccess})
+}
 
+// reviewReport returns the ReviewBoard ranking of review activities
+// within [start, end), top reviewers are mentioned. A detailed report lists
+// all counters of users and has a section of counters of each user in each
+// issue and PR.
+func reviewReport(
+	ctx context.Context, fetcher gh.Fetcher, cfg config.Review, kind string, start, end time.Time,
+	detailed bool,
+) (*report.Report, error) {
+	reviews, daily, err := collectReviewRange(ctx, fetcher, cfg, kind, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	rs := reviewSlice{}
+	for user, r := range reviews {
+		if len(r.metrics()) == 0 {
+			// The user does not review.
+			continue
+		}
+		rs = append(rs, rankedReview{review: r, user: user, score: r.score(cfg.Weights, cfg.Caps)})
+	}
+	sort.Sort(rs)
+
+	section := report.Section{}
+	issueSection := report.Section{Title: "Issues and PRs"}
+	for i, r := range rs {
+		log.Infof("#%d %s (%g) %v", i+1, r.user, r.score, r.metrics())
+		row := report.Row{
+			Rank:    i + 1,
+			Name:    r.user,
+			Score:   report.Score(r.score),
+			Metrics: r.metrics(),
+		}
+		if !detailed {
+			if i < cfg.MentionTop {
+				row.Mentions = []string{r.user}
+			}
+			section.Rows = append(section.Rows, row)
+			continue
+		}
+		section.Title = "Users"
+		row.Metrics = r.counters()
+		section.Rows = append(section.Rows, row)
+		urls := make([]string, 0, len(r.issues))
+		for url := range r.issues {
+			urls = append(urls, url)
+		}
+		sort.Strings(urls)
+		for _, url := range urls {
+			counts := r.issues[url]
+			one := review{issues: map[string]review{url: counts}}
+			issueSection.Rows = append(issueSection.Rows, report.Row{
+				Name:    r.user,
+				Ref:     issueRef(url),
+				URL:     url,
+				Score:   report.Score(one.score(cfg.Weights, cfg.Caps)),
+				Metrics: counts.counters(),
+			})
+		}
+	}
+	sections := []report.Section{section}
+	charts := []report.Chart(nil)
+	if detailed {
+		sections = append(sections, issueSection)
+		charts = []report.Chart{
+			{Title: "Activities by repo", Kind: report.ChartBar, Points: repoActivities(reviews)},
+			{Title: "Activities by day", Kind: report.ChartTimeline, Points: daily},
+		}
+	}
+	return &report.Report{
+		Title:    fmt.Sprintf("ReviewBoard 👍 - %s", kind),
+		Sections: sections,
+		Charts:   charts,
+		Empty:    "No reviews 😢",
+		Start:    start,
+		End:      end,
+	}, nil
+}
+
+// collectReviewRange collects reviews of users day by day within
+// [start, end), it also returns the number of activities of each day.
+func collectReviewRange(
+	ctx context.Context, fetcher gh.Fetcher, cfg config.Review, kind string, start, end time.Time,
+) (map[string]review, []report.Point, error) {
+	c := newReviewConfig(cfg, start, end)
 	log.Info("review range", start, end)
 	current := start
 	next := current.Add(24 * time.Hour)
 	reviews := make(map[string]review)
+	daily := make([]report.Point, 0)
 	for !(current.Equal(end) || current.After(end)) {
 		// Date if formated in time.RFC3339.
 		// updated:2021-05-23T21:00:00+08:00..2021-05-24T21:00:00+08:00
 		currentRFC3339 := current.Format(time.RFC3339)
 		nextRFC3339 := next.Format(time.RFC3339)
 		updateRange := fmt.Sprintf(" updated:%s..%s", currentRFC3339, nextRFC3339)
-		fmt.Printf("[%s] %s -%s\n", time.Now().Format(time.RFC3339), kind, updateRange)
+		fmt.Fprintf(os.Stderr, "[%s] %s -%s\n", time.Now().Format(time.RFC3339), kind, updateRange)
 		projects := make(map[string][]*github.IssuesSearchResult)
+		issues := make([]*github.Issue, 0)
 		for _, proj := range cfg.Repos {
+			repoLabels := labelFilter{allow: proj.AllowLabels, block: proj.BlockLabels}
 			for _, query := range proj.PRQuery {
 				query = strings.TrimSpace(query)
 				query += updateRange
 				log.Info("query: ", query)
-				results, err := gh.SearchIssues(ctx, client, query)
+				results, err := fetcher.SearchIssues(ctx, query)
 				if err != nil {
-					return err
+					return nil, nil, err
 				}
 				projects[proj.Name] = append(projects[proj.Name], results...)
+				for _, res := range results {
+					for _, issue := range res.Issues {
+						if c.labels.isBlocked(issue.Labels) || repoLabels.isBlocked(issue.Labels) {
+							log.Infof("filter by labels, url:%s", issue.GetHTMLURL())
+							continue
+						}
+						issues = append(issues, issue)
+					}
+				}
 			}
 		}
 		log.Debug("projects issues: ", debug.PrettyFormat(projects))
-		for repo, results := range projects {
-			_ = repo
-			for _, res := range results {
-				if len(res.Issues) == 0 {
-					continue
-				}
-				err := collectReviews(ctx, c, client, res.Issues, reviews)
-				if err != nil {
-					panic(err)
-				}
-			}
+		before := totalActivities(reviews)
+		if err := collectReviews(ctx, c, fetcher, issues, reviews); err != nil {
+			return nil, nil, err
 		}
+		daily = append(daily, report.Point{
+			Label: current.Format("01-02"),
+			Value: float64(totalActivities(reviews) - before),
+		})
 		current = next
 		next = current.Add(24 * time.Hour)
 		log.Infof("reviews: %v", reviews)
 	}
-
-	rs := reviewSlice{}
-	for user, r := range reviews {
-		rs = append(rs, struct {
-			review
-			user string
-		}{r, user})
-	}
-
-	buf := strings.Builder{}
-	for i, r := range rs {
-		user, review := r.user, r.review
-		reviewStr := review.String()
-		if len(reviewStr) == 0 {
-			// The user does not review.
-			continue
-		}
-		trophy := fmt.Sprint("#", i+1)
-		userReview := fmt.Sprintf("%s **%s**\n%s\n\n",
-			markdown.Escape(trophy), markdown.Escape(user), markdown.Escape(review.String()))
-		log.Info(userReview)
-		buf.WriteString(userReview)
-	}
-	if buf.Len() == 0 {
-		buf.WriteString("No reviews 😢")
-	}
-	buf.WriteString(fmt.Sprintf("\n[%s, %s]", start.Format(timeFormat), end.Format(timeFormat)))
-	log.Debug("reviews: ", buf.String())
-	bot := feishu.WebhookBot{Token: cfg.FeishuWebhookToken, IsTest: cfg1.IsOnlyPrintMsg}
-	return bot.SendMarkdownMessage(ctx, fmt.Sprintf("ReviewBoard 👍 - %s", kind), buf.String(), feishu.TitleColorGreen)
+	return reviews, daily, nil
 }
 
 type review struct {
@@ -210,67 +299,194 @@ type review struct {
 	issueCreates int
 	// How many labels does one add?
 	labelAdds int
+	// How many other triage events, e.g. assigned, does one make?
+	triages int
+	// Counts of each issue or PR, keyed by its URL.
+	issues map[string]review
 }
 
-func (r *review) String() string {
-	parts := make([]string, 0)
-	if r.prLGTMs != 0 {
-		parts = append(parts, fmt.Sprintf("LGTM: %d", r.prLGTMs))
+// counters returns all counts.
+func (r *review) counters() []report.Metric {
+	return []report.Metric{
+		report.Count("LGTM", r.prLGTMs),
+		report.Count("PR comments", r.prComments),
+		report.Count("issue comments", r.issueComments),
+		report.Count("create issues", r.issueCreates),
+		report.Count("add labels", r.labelAdds),
+		report.Count("triage", r.triages),
 	}
-	if r.prComments != 0 {
-		parts = append(parts, fmt.Sprintf("PR comments: %d", r.prComments))
+}
+
+// metrics returns non-zero counts.
+func (r *review) metrics() []report.Metric {
+	metrics := make([]report.Metric, 0)
+	for _, m := range r.counters() {
+		if m.Value != 0 {
+			metrics = append(metrics, m)
+		}
 	}
-	if r.issueComments != 0 {
-		parts = append(parts, fmt.Sprintf("issue comments: %d", r.issueComments))
+	return metrics
+}
+
+// total returns the sum of all counts.
+func (r *review) total() int {
+	return r.prLGTMs + r.prComments + r.issueComments + r.issueCreates + r.labelAdds + r.triages
+}
+
+// totalActivities returns the sum of all counts of all users.
+func totalActivities(reviews map[string]review) int {
+	n := 0
+	for _, r := range reviews {
+		n += r.total()
 	}
-	if r.issueCreates != 0 {
-		parts = append(parts, fmt.Sprintf("create issues: %d", r.issueCreates))
+	return n
+}
+
+// repoActivities returns the sum of all counts in each repository, the
+// most active first.
+func repoActivities(reviews map[string]review) []report.Point {
+	byRepo := make(map[string]int)
+	for _, r := range reviews {
+		for url, counts := range r.issues {
+			repo, _ := splitIssueURL(url)
+			byRepo[repo] += counts.total()
+		}
 	}
-	if r.labelAdds != 0 {
-		parts = append(parts, fmt.Sprintf("add labels: %d", r.labelAdds))
+	points := make([]report.Point, 0, len(byRepo))
+	for repo, n := range byRepo {
+		points = append(points, report.Point{Label: repo, Value: float64(n)})
 	}
-	return strings.Join(parts, ", ")
+	sort.Slice(points, func(i, j int) bool {
+		if points[i].Value != points[j].Value {
+			return points[i].Value > points[j].Value
+		}
+		return points[i].Label < points[j].Label
+	})
+	return points
 }
 
-func (r *review) score() float64 {
-	s := 1.0
-	if r.prLGTMs != 0 {
-		s += float64(r.prLGTMs) * 2.0
+// issueRef returns owner/repo#number of an issue or PR URL, e.g.
+// https://github.com/pingcap/tidb/pull/1 is pingcap/tidb#1.
+func issueRef(url string) string {
+	repo, number := splitIssueURL(url)
+	if len(number) == 0 {
+		return url
 	}
-	if r.prComments != 0 {
-		s += float64(r.prComments) * 1.0
+	return fmt.Sprintf("%s#%s", repo, number)
+}
+
+// splitIssueURL returns owner/repo and number of an issue or PR URL,
+// number is empty if it is not such an URL.
+func splitIssueURL(url string) (string, string) {
+	parts := strings.Split(strings.TrimPrefix(url, "https://github.com/"), "/")
+	if len(parts) != 4 {
+		return url, ""
 	}
-	if r.issueComments != 0 {
-		s += float64(r.issueComments) * 1.0
+	return parts[0] + "/" + parts[1], parts[3]
+}
+
+// merge adds counts of the given issue or PR.
+func (r *review) merge(issueURL string, o review) {
+	r.add(o)
+	if r.issues == nil {
+		r.issues = make(map[string]review)
 	}
-	if r.issueCreates != 0 {
-		s += float64(r.issueCreates) * 2.0
+	i := r.issues[issueURL]
+	i.add(o)
+	r.issues[issueURL] = i
+}
+
+func (r *review) add(o review) {
+	r.prLGTMs += o.prLGTMs
+	r.prComments += o.prComments
+	r.issueComments += o.issueComments
+	r.issueCreates += o.issueCreates
+	r.labelAdds += o.labelAdds
+	r.triages += o.triages
+}
+
+// score sums weighted counts on top of 1, counts of one issue or PR are
+// capped.
+func (r *review) score(weights config.Weights, caps config.Caps) float64 {
+	s := 1.0
+	for _, i := range r.issues {
+		s += float64(capCount(i.prLGTMs, caps.LGTM)) * weights.LGTM
+		s += float64(capCount(i.prComments, caps.PRComment)) * weights.PRComment
+		s += float64(capCount(i.issueComments, caps.IssueComment)) * weights.IssueComment
+		s += float64(capCount(i.issueCreates, caps.IssueCreate)) * weights.IssueCreate
+		s += float64(capCount(i.labelAdds, caps.LabelAdd)) * weights.LabelAdd
+		s += float64(capCount(i.triages, caps.Triage)) * weights.Triage
 	}
-	if r.labelAdds != 0 {
-		s += float64(r.labelAdds) * 0.5
+	// Round to hide floating point noise of fractional weights.
+	return math.Round(s*100) / 100
+}
+
+func capCount(n, max int) int {
+	if max > 0 && n > max {
+		return max
 	}
-	return s
+	return n
 }
 
-type reviewSlice []struct {
+type rankedReview struct {
 	review
-	user string
+	user  string
+	score float64
 }
 
-func (x reviewSlice) Len() int           { return len(x) }
-func (x reviewSlice) Less(i, j int) bool { return x[i].score() < x[j].score() }
-func (x reviewSlice) Swap(i, j int)      { x[i], x[j] = x[j], x[i] }
+// reviewSlice sorts reviews by score in descending order,
+// ties are broken by user name.
+type reviewSlice []rankedReview
+
+func (x reviewSlice) Len() int { return len(x) }
+func (x reviewSlice) Less(i, j int) bool {
+	if x[i].score != x[j].score {
+		return x[i].score > x[j].score
+	}
+	return x[i].user < x[j].user
+}
+func (x reviewSlice) Swap(i, j int) { x[i], x[j] = x[j], x[i] }
 
 type reviewConfig struct {
 	lgtmComments   []string
 	blockComments  []string
-	blockLabels    []string
+	labels         labelFilter
 	allowUsers     map[string]bool
 	blockUsers     map[string]bool
+	triageEvents   map[string]bool
+	concurrency    int
 	startTimestamp time.Time
 	endTimestamp   time.Time
 }
 
+func newReviewConfig(cfg config.Review, start, end time.Time) *reviewConfig {
+	c := &reviewConfig{
+		lgtmComments:   cfg.LGTMComments,
+		blockComments:  cfg.BlockComments,
+		labels:         labelFilter{allow: cfg.AllowLabels, block: cfg.BlockLabels},
+		allowUsers:     make(map[string]bool, len(cfg.AllowUsers)),
+		blockUsers:     make(map[string]bool, len(cfg.BlockUsers)),
+		triageEvents:   make(map[string]bool),
+		concurrency:    cfg.Concurrency,
+		startTimestamp: start,
+		endTimestamp:   end,
+	}
+	for i := range cfg.AllowUsers {
+		c.allowUsers[cfg.AllowUsers[i]] = true
+	}
+	for i := range cfg.BlockUsers {
+		c.blockUsers[cfg.BlockUsers[i]] = true
+	}
+	triageEvents := cfg.TriageEvents
+	if triageEvents == nil {
+		triageEvents = defaultTriageEvents
+	}
+	for i := range triageEvents {
+		c.triageEvents[triageEvents[i]] = true
+	}
+	return c
+}
+
 // Is the ts within [start, end)?
 func (c *reviewConfig) withinTimeRange(ts time.Time) bool {
 	ts = ts.In(timeZone)
@@ -322,16 +538,18 @@ func (c *reviewConfig) isCommentLGTM(comment string) bool {
 type collector func(
 	ctx context.Context,
 	c *reviewConfig,
-	client *github.Client,
+	fetcher gh.Fetcher,
 	issues []*github.Issue,
 	reviews map[string]review,
 ) error
 
 // collect reviews for the given issues and PRs.
+// Issues are collected by at most c.concurrency workers, each of them
+// runs all collectors for one issue and merges the results into reviews.
 func collectReviews(
 	ctx context.Context,
 	c *reviewConfig,
-	client *github.Client,
+	fetcher gh.Fetcher,
 	issues []*github.Issue,
 	reviews map[string]review,
 ) error {
@@ -340,14 +558,26 @@ func collectReviews(
 		collectPRLGTM,
 		collectPRReviewComments,
 		collectIssueAndPRComments,
+		collectIssueEvents,
 	}
-	for _, collect := range collectors {
-		err := collect(ctx, c, client, issues, reviews)
-		if err != nil {
-			return err
+	var mu sync.Mutex
+	return parallel(ctx, c.concurrency, len(issues), func(ctx context.Context, i int) error {
+		issueReviews := make(map[string]review)
+		for _, collect := range collectors {
+			err := collect(ctx, c, fetcher, issues[i:i+1], issueReviews)
+			if err != nil {
+				return err
+			}
 		}
-	}
-	return nil
+		mu.Lock()
+		defer mu.Unlock()
+		for user, r := range issueReviews {
+			review := reviews[user]
+			review.merge(issues[i].GetHTMLURL(), r)
+			reviews[user] = review
+		}
+		return nil
+	})
 }
 
 // Collect review.prLGTM.
@@ -355,7 +585,7 @@ func collectReviews(
 func collectPRLGTM(
 	ctx context.Context,
 	c *reviewConfig,
-	client *github.Client,
+	fetcher gh.Fetcher,
 	issues []*github.Issue,
 	reviews map[string]review,
 ) error {
@@ -366,7 +596,7 @@ func collectPRLGTM(
 		pr := issue
 		owner, repo := gh.GetRepository(pr)
 		number := pr.GetNumber()
-		prReviews, err := gh.PullRequestsListReviews(ctx, client, owner, repo, number)
+		prReviews, err := fetcher.PullRequestsListReviews(ctx, owner, repo, number)
 		if err != nil {
 			return err
 		}
@@ -396,7 +626,7 @@ func collectPRLGTM(
 func collectPRReviewComments(
 	ctx context.Context,
 	c *reviewConfig,
-	client *github.Client,
+	fetcher gh.Fetcher,
 	issues []*github.Issue,
 	reviews map[string]review,
 ) error {
@@ -407,7 +637,7 @@ func collectPRReviewComments(
 		pr := issue
 		owner, repo := gh.GetRepository(pr)
 		number := pr.GetNumber()
-		prReviews, err := gh.PullRequestsListReviews(ctx, client, owner, repo, number)
+		prReviews, err := fetcher.PullRequestsListReviews(ctx, owner, repo, number)
 		if err != nil {
 			return err
 		}
@@ -424,7 +654,7 @@ func collectPRReviewComments(
 				continue
 			}
 
-			reviewComments, err := gh.PullRequestsListReviewComments(ctx, client, owner, repo, number, *prReview.ID)
+			reviewComments, err := fetcher.PullRequestsListReviewComments(ctx, owner, repo, number, *prReview.ID)
 			if err != nil {
 				return err
 			}
@@ -442,15 +672,15 @@ func collectPRReviewComments(
 func collectIssueAndPRComments(
 	ctx context.Context,
 	c *reviewConfig,
-	client *github.Client,
+	fetcher gh.Fetcher,
 	issues []*github.Issue,
 	reviews map[string]review,
 ) error {
 	for _, issue := range issues {
 		owner, repo := gh.GetRepository(issue)
 		number := issue.GetNumber()
-		comments, err := gh.IssuesListComments(
-			ctx, client, owner, repo, number, &c.startTimestamp)
+		comments, err := fetcher.IssuesListComments(
+			ctx, owner, repo, number, &c.startTimestamp)
 		if err != nil {
 			return err
 		}
@@ -488,7 +718,7 @@ func collectIssueAndPRComments(
 func collectIssueCreates(
 	ctx context.Context,
 	c *reviewConfig,
-	client *github.Client,
+	fetcher gh.Fetcher,
 	issues []*github.Issue,
 	reviews map[string]review,
 ) error {
@@ -506,3 +736,48 @@ func collectIssueCreates(
 	}
 	return nil
 }
+
+// Collect review.labelAdds and review.triages.
+func collectIssueEvents(
+	ctx context.Context,
+	c *reviewConfig,
+	fetcher gh.Fetcher,
+	issues []*github.Issue,
+	reviews map[string]review,
+) error {
+	if len(c.triageEvents) == 0 {
+		return nil
+	}
+	for _, issue := range issues {
+		owner, repo := gh.GetRepository(issue)
+		number := issue.GetNumber()
+		events, err := fetcher.IssuesListIssueEvents(ctx, owner, repo, number)
+		if err != nil {
+			return err
+		}
+		log.Debug("events: ", debug.PrettyFormat(events))
+		for _, event := range events {
+			if event.Actor == nil || !c.triageEvents[event.GetEvent()] {
+				continue
+			}
+			if c.isUserBlocked(*event.Actor.Login) {
+				continue
+			}
+			if *event.Actor.Login == *issue.User.Login {
+				// Do not count author's triage.
+				continue
+			}
+			if !c.withinTimeRange(*event.CreatedAt) {
+				continue
+			}
+			review := reviews[*event.Actor.Login]
+			if event.GetEvent() == "labeled" {
+				review.labelAdds++
+			} else {
+				review.triages++
+			}
+			reviews[*event.Actor.Login] = review
+		}
+	}
+	return nil
+}
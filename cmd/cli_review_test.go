@@ -0,0 +1,95 @@
+// Copyright 2021 ghstats Project Authors. Licensed under MIT.
+
+package cmd
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/overvenus/ghstats/pkg/config"
+)
+
+func TestCapCount(t *testing.T) {
+	cases := []struct {
+		n, max, expect int
+	}{
+		{n: 3, max: 0, expect: 3},
+		{n: 3, max: -1, expect: 3},
+		{n: 3, max: 5, expect: 3},
+		{n: 5, max: 5, expect: 5},
+		{n: 8, max: 5, expect: 5},
+		{n: 0, max: 5, expect: 0},
+	}
+	for _, c := range cases {
+		if n := capCount(c.n, c.max); n != c.expect {
+			t.Errorf("capCount(%d, %d): expect %d, got %d", c.n, c.max, c.expect, n)
+		}
+	}
+}
+
+func TestReviewScore(t *testing.T) {
+	weights := config.Weights{LGTM: 2, PRComment: 1, IssueComment: 1, IssueCreate: 2, LabelAdd: 0.5, Triage: 0.5}
+	cases := []struct {
+		name   string
+		issues map[string]review
+		caps   config.Caps
+		expect float64
+	}{
+		{name: "no reviews", expect: 1},
+		{
+			name:   "weighted",
+			issues: map[string]review{"pr/1": {prLGTMs: 1, prComments: 2, labelAdds: 1}},
+			expect: 1 + 2 + 2 + 0.5,
+		},
+		{
+			name:   "uncapped",
+			issues: map[string]review{"pr/1": {prComments: 30}},
+			expect: 31,
+		},
+		// Caps apply to each issue, not to the sum.
+		{
+			name:   "capped per issue",
+			issues: map[string]review{"pr/1": {prComments: 30}, "pr/2": {prComments: 4}, "issue/3": {issueComments: 12}},
+			caps:   config.Caps{PRComment: 10, IssueComment: 10},
+			expect: 1 + 10 + 4 + 10,
+		},
+		{
+			name:   "fractional weights",
+			issues: map[string]review{"issue/1": {labelAdds: 1, triages: 1}, "issue/2": {triages: 3}},
+			expect: 1 + 0.5 + 0.5 + 1.5,
+		},
+	}
+	for _, c := range cases {
+		r := review{}
+		for url, i := range c.issues {
+			r.merge(url, i)
+		}
+		if s := r.score(weights, c.caps); s != c.expect {
+			t.Errorf("%s: expect %g, got %g", c.name, c.expect, s)
+		}
+	}
+}
+
+func TestReviewSlice(t *testing.T) {
+	rs := reviewSlice{
+		{user: "carol", score: 3},
+		{user: "bob", score: 5},
+		{user: "dave", score: 3},
+		{user: "alice", score: 3},
+		{user: "eve", score: 1.5},
+	}
+	// Ties are broken by user names, regardless of the input order.
+	for i := 0; i < 2; i++ {
+		sort.Sort(rs)
+		users := make([]string, 0, len(rs))
+		for _, r := range rs {
+			users = append(users, r.user)
+		}
+		expect := []string{"bob", "alice", "carol", "dave", "eve"}
+		if !reflect.DeepEqual(users, expect) {
+			t.Errorf("expect %q, got %q", expect, users)
+		}
+		rs[0], rs[len(rs)-1] = rs[len(rs)-1], rs[0]
+	}
+}
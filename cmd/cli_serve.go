@@ -0,0 +1,96 @@
+// Copyright 2021 ghstats Project Authors. Licensed under MIT.
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/overvenus/ghstats/pkg/config"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(newServeCommand())
+}
+
+// newServeCommand returns SERVE command
+func newServeCommand() *cobra.Command {
+	command := &cobra.Command{
+		Use:   "serve",
+		Short: "Serve metrics, webhooks and bot commands over HTTP 📡",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfgPath, err := cmd.Flags().GetString("config")
+			if err != nil {
+				return err
+			}
+			cfg, err := config.ReadConfig(cfgPath)
+			if err != nil {
+				return err
+			}
+			addr, err := cmd.Flags().GetString("addr")
+			if err != nil {
+				return err
+			}
+			enableMetrics, err := cmd.Flags().GetBool("metrics")
+			if err != nil {
+				return err
+			}
+			enableWebhook, err := cmd.Flags().GetBool("webhook")
+			if err != nil {
+				return err
+			}
+			enableFeishu, err := cmd.Flags().GetBool("feishu")
+			if err != nil {
+				return err
+			}
+			interval, err := cmd.Flags().GetDuration("interval")
+			if err != nil {
+				return err
+			}
+			days, err := cmd.Flags().GetInt("days")
+			if err != nil {
+				return err
+			}
+			if !enableMetrics && !enableWebhook && !enableFeishu {
+				return errors.New("nothing to serve, set --metrics, --webhook or --feishu")
+			}
+			if enableWebhook && len(cfg.GitHub.WebhookSecret) == 0 {
+				return errors.New("webhook-secret is required to verify webhook deliveries")
+			}
+			if enableFeishu && len(cfg.Feishu.VerificationToken) == 0 && len(cfg.Feishu.EncryptKey) == 0 {
+				return errors.New("feishu verification-token or encrypt-key is required to verify events")
+			}
+			if enableFeishu && (len(cfg.Feishu.AppID) == 0 || len(cfg.Feishu.AppSecret) == 0) {
+				return errors.New("feishu app-id and app-secret are required to reply bot commands")
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			mux := http.NewServeMux()
+			if enableMetrics {
+				exporter := newMetricsExporter(cfg, days)
+				mux.Handle("/metrics", exporter.registry)
+				go exporter.run(ctx, interval)
+			}
+			if enableWebhook {
+				mux.Handle("/webhook", newWebhookHandler(cfg))
+			}
+			if enableFeishu {
+				mux.Handle("/feishu", newFeishuBot(cfg))
+			}
+			log.Infof("serve on %s", addr)
+			return http.ListenAndServe(addr, mux)
+		},
+	}
+	command.Flags().String("addr", ":9090", "Address to listen on")
+	command.Flags().Bool("metrics", false, "Serve review and PTAL metrics on /metrics in Prometheus text format")
+	command.Flags().Bool("webhook", false, "Receive GitHub webhooks on /webhook and send PRs that touch allow-pkgs")
+	command.Flags().Bool("feishu", false, "Receive Feishu events on /feishu and reply bot commands, e.g. /ptal")
+	command.Flags().Duration("interval", 30*time.Minute, "How often metrics are collected")
+	command.Flags().Int("days", 7, "Review metrics are collected within the past days")
+	return command
+}
@@ -0,0 +1,195 @@
+// Copyright 2021 ghstats Project Authors. Licensed under MIT.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/overvenus/ghstats/pkg/config"
+	"github.com/overvenus/ghstats/pkg/gh"
+	"github.com/overvenus/ghstats/pkg/store"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(newSyncCommand())
+}
+
+// newSyncCommand returns SYNC command
+func newSyncCommand() *cobra.Command {
+	command := &cobra.Command{
+		Use:   "sync",
+		Short: "Sync issues, reviews and comments to the local store 💾",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfgPath, err := cmd.Flags().GetString("config")
+			if err != nil {
+				return err
+			}
+			cfg, err := config.ReadConfig(cfgPath)
+			if err != nil {
+				return err
+			}
+			days, err := cmd.Flags().GetInt("days")
+			if err != nil {
+				return err
+			}
+			maxPages, err := cmd.Flags().GetInt("max-pages")
+			if err != nil {
+				return err
+			}
+			s, err := store.Open(cfg.Store.Path, false)
+			if err != nil {
+				return err
+			}
+			defer s.Close()
+
+			ctx := context.Background()
+			defaultSince := time.Now().In(timeZone).Add(-time.Duration(days) * 24 * time.Hour)
+			if len(cfg.Review.Repos) != 0 {
+				fetcher, err := newGithubFetcher(ctx, cfg.GitHub, cfg.Review.GithubToken)
+				if err != nil {
+					return err
+				}
+				for _, proj := range cfg.Review.Repos {
+					for _, query := range proj.PRQuery {
+						err := syncQuery(ctx, fetcher, s, strings.TrimSpace(query), defaultSince)
+						if err != nil {
+							return err
+						}
+					}
+				}
+			}
+			if len(cfg.PTAL.Repos) != 0 {
+				fetcher, err := newGithubFetcher(ctx, cfg.GitHub, cfg.PTAL.GithubToken)
+				if err != nil {
+					return err
+				}
+				for _, proj := range cfg.PTAL.Repos {
+					if len(proj.PROwnerRepo) == 0 {
+						continue
+					}
+					if err := syncPullRequests(ctx, fetcher, s, proj.PROwnerRepo, maxPages); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		},
+	}
+	command.Flags().Int("days", 30, "How many days to sync back for queries that have never been synced")
+	command.Flags().Int("max-pages", 20, "How many pages of pull requests to sync for each PTAL repo")
+	return command
+}
+
+// syncQuery saves issues updated since the last sync of the query,
+// along with their comments, events and reviews.
+func syncQuery(
+	ctx context.Context, fetcher gh.Fetcher, s *store.Store, query string, defaultSince time.Time,
+) error {
+	cursor := store.SearchIssueCursor(query)
+	since, ok, err := s.Cursor(cursor)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		since = defaultSince
+	}
+	syncStart := time.Now()
+	fmt.Fprintf(os.Stderr, "[%s] sync %s since %s\n", syncStart.Format(time.RFC3339), query, since.Format(time.RFC3339))
+	results, err := fetcher.SearchIssues(ctx, fmt.Sprintf("%s updated:>=%s", query, since.Format(time.RFC3339)))
+	if err != nil {
+		return err
+	}
+	for _, res := range results {
+		for _, issue := range res.Issues {
+			owner, repo := gh.GetRepository(issue)
+			number := issue.GetNumber()
+			log.Infof("sync %s", store.IssueKey(owner, repo, number))
+			comments, err := fetcher.IssuesListComments(ctx, owner, repo, number, nil)
+			if err != nil {
+				return err
+			}
+			if err := s.PutIssueComments(owner, repo, number, comments); err != nil {
+				return err
+			}
+			events, err := fetcher.IssuesListIssueEvents(ctx, owner, repo, number)
+			if err != nil {
+				return err
+			}
+			if err := s.PutIssueEvents(owner, repo, number, events); err != nil {
+				return err
+			}
+			if issue.IsPullRequest() {
+				reviews, err := fetcher.PullRequestsListReviews(ctx, owner, repo, number)
+				if err != nil {
+					return err
+				}
+				for _, review := range reviews {
+					reviewComments, err := fetcher.PullRequestsListReviewComments(
+						ctx, owner, repo, number, review.GetID())
+					if err != nil {
+						return err
+					}
+					err = s.PutReviewComments(owner, repo, number, review.GetID(), reviewComments)
+					if err != nil {
+						return err
+					}
+				}
+				if err := s.PutReviews(owner, repo, number, reviews); err != nil {
+					return err
+				}
+			}
+			// Save the issue at last, so that an interrupted sync
+			// never leaves an issue without its activities.
+			if err := s.PutIssue(query, owner, repo, issue); err != nil {
+				return err
+			}
+		}
+	}
+	return s.SetCursor(cursor, syncStart)
+}
+
+// syncPullRequests saves the most recent pull requests of a repository,
+// changed files are only fetched for new or updated pull requests.
+func syncPullRequests(
+	ctx context.Context, fetcher gh.Fetcher, s *store.Store, ownerRepo string, maxPages int,
+) error {
+	repoInfs := strings.SplitN(ownerRepo, "/", 2)
+	if len(repoInfs) != 2 {
+		return fmt.Errorf("repo str:%v, split strings:%v", ownerRepo, repoInfs)
+	}
+	owner, repo := repoInfs[0], repoInfs[1]
+	syncStart := time.Now()
+	fmt.Fprintf(os.Stderr, "[%s] sync pull requests of %s\n", syncStart.Format(time.RFC3339), ownerRepo)
+	prs, err := fetcher.PullRequestsList(ctx, owner, repo, maxPages)
+	if err != nil {
+		return err
+	}
+	for _, pr := range prs {
+		number := pr.GetNumber()
+		old, err := s.PullRequest(owner, repo, number)
+		if err != nil {
+			return err
+		}
+		if old != nil && old.GetUpdatedAt().Equal(pr.GetUpdatedAt()) {
+			continue
+		}
+		log.Infof("sync %s", store.IssueKey(owner, repo, number))
+		files, err := fetcher.PullRequestsListFiles(ctx, owner, repo, number)
+		if err != nil {
+			return err
+		}
+		if err := s.PutFiles(owner, repo, number, files); err != nil {
+			return err
+		}
+		if err := s.PutPullRequest(owner, repo, pr); err != nil {
+			return err
+		}
+	}
+	return s.SetCursor(store.PullRequestsCursor(owner, repo), syncStart)
+}
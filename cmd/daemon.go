@@ -0,0 +1,194 @@
+// Copyright 2021 ghstats Project Authors. Licensed under MIT.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/overvenus/ghstats/pkg/config"
+	"github.com/robfig/cron/v3"
+	log "github.com/sirupsen/logrus"
+)
+
+// jobState is the last run of a schedule.
+type jobState struct {
+	LastRun      time.Time  `json:"last_run"`
+	LastSuccess  *time.Time `json:"last_success,omitempty"`
+	LastFailure  *time.Time `json:"last_failure,omitempty"`
+	LastError    string     `json:"last_error,omitempty"`
+	LastDuration string     `json:"last_duration,omitempty"`
+}
+
+// daemonState persists states of schedules in a JSON file.
+type daemonState struct {
+	path string
+
+	mu   sync.Mutex
+	jobs map[string]*jobState
+}
+
+func loadDaemonState(path string) (*daemonState, error) {
+	s := &daemonState{path: path, jobs: make(map[string]*jobState)}
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &s.jobs); err != nil {
+		return nil, fmt.Errorf("corrupted daemon state %s: %v", path, err)
+	}
+	return s, nil
+}
+
+// get returns a copy of the state of the job, ok is false if it never runs.
+func (s *daemonState) get(name string) (jobState, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	js, ok := s.jobs[name]
+	if !ok {
+		return jobState{}, false
+	}
+	return *js, true
+}
+
+// update updates the state of the job and saves all states.
+func (s *daemonState) update(name string, fn func(js *jobState)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	js, ok := s.jobs[name]
+	if !ok {
+		js = &jobState{}
+		s.jobs[name] = js
+	}
+	fn(js)
+	if err := s.save(); err != nil {
+		log.Errorf("save daemon state failed: %v", err)
+	}
+}
+
+// save writes states to a temporary file and renames it, so that a crash
+// never leaves a partial file.
+func (s *daemonState) save() error {
+	b, err := json.MarshalIndent(s.jobs, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(s.path), filepath.Base(s.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
+
+// daemonJob runs a gh command as a subprocess, so that a failed or
+// crashed command does not take down the daemon.
+type daemonJob struct {
+	name  string
+	exe   string
+	args  []string
+	state *daemonState
+}
+
+func newDaemonJob(sched config.Schedule, cfgPath, exe string, state *daemonState) *daemonJob {
+	if len(sched.Config) != 0 {
+		cfgPath = sched.Config
+	}
+	args := append([]string{"-c", cfgPath}, strings.Fields(sched.Command)...)
+	return &daemonJob{name: sched.Name, exe: exe, args: args, state: state}
+}
+
+// Run implements cron.Job.
+func (j *daemonJob) Run() {
+	start := time.Now()
+	j.state.update(j.name, func(js *jobState) { js.LastRun = start })
+	log.Infof("[%s] run gh %s", j.name, strings.Join(j.args, " "))
+
+	cmd := exec.Command(j.exe, j.args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	err := cmd.Run()
+
+	end := time.Now()
+	duration := end.Sub(start).Round(time.Millisecond)
+	j.state.update(j.name, func(js *jobState) {
+		js.LastDuration = duration.String()
+		if err != nil {
+			js.LastFailure = &end
+			js.LastError = err.Error()
+			return
+		}
+		js.LastSuccess = &end
+		js.LastError = ""
+	})
+	if err != nil {
+		log.Errorf("[%s] failed in %s: %v", j.name, duration, err)
+		return
+	}
+	log.Infof("[%s] succeeded in %s", j.name, duration)
+}
+
+// scheduleSpec returns the cron spec of the schedule in its time zone.
+func scheduleSpec(sched config.Schedule) string {
+	if len(sched.TimeZone) == 0 || strings.HasPrefix(sched.Cron, "CRON_TZ=") || strings.HasPrefix(sched.Cron, "TZ=") {
+		return sched.Cron
+	}
+	return fmt.Sprintf("CRON_TZ=%s %s", sched.TimeZone, sched.Cron)
+}
+
+// newDaemon returns a cron scheduler of the schedules, runs of a schedule
+// never overlap. Schedules that are missed since their last runs are
+// returned to be caught up.
+func newDaemon(
+	schedules []config.Schedule, cfgPath, exe string, state *daemonState, now time.Time,
+) (*cron.Cron, []cron.Job, error) {
+	logger := cron.PrintfLogger(log.StandardLogger())
+	c := cron.New(cron.WithLogger(logger))
+	missed := make([]cron.Job, 0)
+	names := make(map[string]bool)
+	for _, sched := range schedules {
+		if len(sched.Name) == 0 {
+			sched.Name = sched.Command
+		}
+		if names[sched.Name] {
+			return nil, nil, fmt.Errorf("duplicated schedule %q", sched.Name)
+		}
+		names[sched.Name] = true
+		if len(strings.Fields(sched.Command)) == 0 {
+			return nil, nil, fmt.Errorf("schedule %q has no command", sched.Name)
+		}
+		s, err := cron.ParseStandard(scheduleSpec(sched))
+		if err != nil {
+			return nil, nil, fmt.Errorf("schedule %q: %v", sched.Name, err)
+		}
+		job := cron.NewChain(cron.Recover(logger), cron.SkipIfStillRunning(logger)).
+			Then(newDaemonJob(sched, cfgPath, exe, state))
+		c.Schedule(s, job)
+
+		if js, ok := state.get(sched.Name); ok && sched.CatchUp {
+			if next := s.Next(js.LastRun); next.Before(now) {
+				log.Infof("[%s] missed the run at %s", sched.Name, next.Format(time.RFC3339))
+				missed = append(missed, job)
+			}
+		}
+	}
+	return c, missed, nil
+}
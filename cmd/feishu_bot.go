@@ -0,0 +1,315 @@
+// Copyright 2021 ghstats Project Authors. Licensed under MIT.
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/overvenus/ghstats/pkg/config"
+	"github.com/overvenus/ghstats/pkg/feishu"
+	"github.com/overvenus/ghstats/pkg/notify"
+	"github.com/overvenus/ghstats/pkg/report"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// botTimeout bounds a bot command, monthly reviews may take a while.
+	botTimeout = 30 * time.Minute
+	// Feishu retries an event if it is not acknowledged in time, events
+	// are deduplicated within botEventTTL.
+	botEventTTL = time.Hour
+)
+
+const botHelp = `Commands:
+- /ptal [repo]: PRs that need to be reviewed
+- /pkgs [daily|weekly|monthly]: PRs that change the packages
+- /review [daily|weekly|monthly]: the ReviewBoard
+- /stats @user [daily|weekly|monthly]: reviews of a GitHub user or a mapped Feishu user, weekly by default`
+
+// feishuBot replies bot commands received by the Feishu event
+// subscription. Replies are sent by the Feishu app to the chat where
+// commands are sent.
+type feishuBot struct {
+	cfg     *config.Config
+	decoder feishu.EventDecoder
+
+	mu     sync.Mutex
+	events map[string]time.Time
+}
+
+func newFeishuBot(cfg *config.Config) *feishuBot {
+	return &feishuBot{
+		cfg: cfg,
+		decoder: feishu.EventDecoder{
+			VerificationToken: cfg.Feishu.VerificationToken,
+			EncryptKey:        cfg.Feishu.EncryptKey,
+		},
+		events: make(map[string]time.Time),
+	}
+}
+
+// ServeHTTP implements http.Handler.
+func (b *feishuBot) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, 1<<20))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	event, err := b.decoder.Decode(r.Header, body)
+	if err == feishu.ErrInvalidToken || err == feishu.ErrInvalidSignature || err == feishu.ErrUnencrypted {
+		log.Warnf("invalid feishu event: %v", err)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		log.Warnf("decode feishu event failed: %v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	switch event.EventType() {
+	case feishu.EventTypeURLVerification:
+		json.NewEncoder(w).Encode(map[string]string{"challenge": event.Challenge})
+		return
+	case feishu.EventTypeMessageReceive:
+		if b.isDuplicated(event.Header.EventID) {
+			log.Infof("ignore duplicated feishu event %s", event.Header.EventID)
+			break
+		}
+		msg := feishu.MessageEvent{}
+		if err := json.Unmarshal(event.Event, &msg); err != nil {
+			log.Warnf("decode feishu message failed: %v", err)
+			break
+		}
+		name, args, err := messageCommand(msg.Message, b.cfg.Users)
+		if err != nil {
+			log.Infof("ignore feishu message %s: %v", msg.Message.MessageID, err)
+			break
+		}
+		if len(name) == 0 {
+			break
+		}
+		log.Infof("feishu bot command from %s in %s: /%s %s",
+			msg.Sender.SenderID.OpenID, msg.Message.ChatID, name, strings.Join(args, " "))
+		// Feishu expects a response within 3 seconds.
+		go b.run(msg.Message.ChatID, name, args)
+	default:
+		log.Debugf("ignore feishu event %s", event.EventType())
+	}
+	w.Write([]byte("{}"))
+}
+
+// githubLogin returns the GitHub login of the Feishu user, or "" if the
+// user is not in users.
+func githubLogin(users []config.User, id feishu.UserID) string {
+	for _, u := range users {
+		if (len(u.FeishuOpenID) != 0 && u.FeishuOpenID == id.OpenID) ||
+			(len(u.FeishuUserID) != 0 && u.FeishuUserID == id.UserID) {
+			return u.GitHub
+		}
+	}
+	return ""
+}
+
+// isDuplicated returns true if the event has been received.
+func (b *feishuBot) isDuplicated(id string) bool {
+	now := time.Now()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for eid, t := range b.events {
+		if now.Sub(t) > botEventTTL {
+			delete(b.events, eid)
+		}
+	}
+	if _, ok := b.events[id]; ok {
+		return true
+	}
+	b.events[id] = now
+	return false
+}
+
+// messageCommand returns the command in the message. Mapped Feishu users
+// are mentioned by GitHub logins, e.g. in "/stats @alice", other mentions,
+// e.g. the bot, are dropped, as display names may contain spaces.
+func messageCommand(m feishu.Message, users []config.User) (string, []string, error) {
+	mentions := make([]feishu.Mention, 0, len(m.Mentions))
+	for _, mention := range m.Mentions {
+		mention.Name = githubLogin(users, mention.ID)
+		mentions = append(mentions, mention)
+	}
+	m.Mentions = mentions
+	text, err := m.Text()
+	if err != nil {
+		return "", nil, err
+	}
+	name, args := parseBotCommand(text)
+	return name, args, nil
+}
+
+// parseBotCommand returns the name and arguments of a command, e.g.
+// "@bot /stats @alice weekly" returns "stats" and ["alice", "weekly"].
+// Mentions before the command are skipped.
+func parseBotCommand(text string) (string, []string) {
+	fields := strings.Fields(text)
+	for i, field := range fields {
+		if strings.HasPrefix(field, "@") {
+			continue
+		}
+		if !strings.HasPrefix(field, "/") {
+			return "", nil
+		}
+		args := make([]string, 0, len(fields)-i-1)
+		for _, arg := range fields[i+1:] {
+			args = append(args, strings.TrimPrefix(arg, "@"))
+		}
+		return strings.ToLower(strings.TrimPrefix(field, "/")), args
+	}
+	return "", nil
+}
+
+// run runs the command and replies to the chat where it is sent.
+func (b *feishuBot) run(chatID, name string, args []string) {
+	ctx, cancel := context.WithTimeout(context.Background(), botTimeout)
+	defer cancel()
+
+	msg, err := b.command(ctx, name, args)
+	if err != nil {
+		log.Errorf("feishu bot command /%s failed: %v", name, err)
+		msg = notify.Message{
+			Report:   &report.Report{Title: fmt.Sprintf("/%s failed 😢", name), Empty: err.Error()},
+			Severity: notify.SeverityDanger,
+		}
+	}
+	notifier, err := notify.New(
+		config.Sink{Type: "feishu-app", Enable: true, FeishuChatID: chatID}, notifyOptions(b.cfg))
+	if err != nil {
+		log.Errorf("feishu bot reply failed: %v", err)
+		return
+	}
+	if err := notifier.Notify(ctx, msg); err != nil {
+		log.Errorf("feishu bot reply failed: %v", err)
+	}
+}
+
+// command returns the reply of the command.
+func (b *feishuBot) command(ctx context.Context, name string, args []string) (notify.Message, error) {
+	now := time.Now().In(timeZone)
+	switch name {
+	case "ptal":
+		cfg := b.cfg.PTAL
+		if len(args) != 0 {
+			cfg.Repos = nil
+			for _, proj := range b.cfg.PTAL.Repos {
+				if strings.EqualFold(proj.Name, args[0]) || strings.EqualFold(proj.PROwnerRepo, args[0]) {
+					cfg.Repos = append(cfg.Repos, proj)
+				}
+			}
+			if len(cfg.Repos) == 0 {
+				return notify.Message{}, fmt.Errorf("unknown repo %q, repos are %s", args[0], b.cfg.PTAL.ReposName())
+			}
+		}
+		fetcher, err := newGithubFetcher(ctx, b.cfg.GitHub, cfg.GithubToken)
+		if err != nil {
+			return notify.Message{}, err
+		}
+		filter, err := newPTALFilter(cfg)
+		if err != nil {
+			return notify.Message{}, err
+		}
+		r, severity, err := ptalReport(ctx, fetcher, cfg, filter)
+		return notify.Message{Report: r, Severity: severity}, err
+
+	case "pkgs":
+		kind, err := botPeriod(args, 0, DailyKind)
+		if err != nil {
+			return notify.Message{}, err
+		}
+		fetcher, err := newGithubFetcher(ctx, b.cfg.GitHub, b.cfg.PTAL.GithubToken)
+		if err != nil {
+			return notify.Message{}, err
+		}
+		r, err := pkgsReport(ctx, fetcher, b.cfg.PTAL, kind, pkgsStart(kind, now), now)
+		return notify.Message{Report: r, Severity: notify.SeverityInfo}, err
+
+	case "review":
+		kind, err := botPeriod(args, 0, DailyKind)
+		if err != nil {
+			return notify.Message{}, err
+		}
+		fetcher, err := newGithubFetcher(ctx, b.cfg.GitHub, b.cfg.Review.GithubToken)
+		if err != nil {
+			return notify.Message{}, err
+		}
+		r, err := reviewReport(ctx, fetcher, b.cfg.Review, kind, reviewStart(kind, now), now, false)
+		return notify.Message{Report: r, Severity: notify.SeveritySuccess}, err
+
+	case "stats":
+		if len(args) == 0 {
+			return notify.Message{}, errors.New("usage: /stats @user [daily|weekly|monthly]")
+		}
+		kind, err := botPeriod(args, 1, WeeklyKind)
+		if err != nil {
+			return notify.Message{}, err
+		}
+		fetcher, err := newGithubFetcher(ctx, b.cfg.GitHub, b.cfg.Review.GithubToken)
+		if err != nil {
+			return notify.Message{}, err
+		}
+		r, err := reviewReport(ctx, fetcher, b.cfg.Review, kind, reviewStart(kind, now), now, true)
+		if err != nil {
+			return notify.Message{}, err
+		}
+		return notify.Message{Report: userReport(r, args[0]), Severity: notify.SeveritySuccess}, nil
+	}
+	return notify.Message{Report: &report.Report{Title: "ghstats 🤖", Empty: botHelp}}, nil
+}
+
+// botPeriod returns the period kind in args[i], or def if it is absent.
+func botPeriod(args []string, i int, def string) (string, error) {
+	if len(args) <= i {
+		return def, nil
+	}
+	for _, kind := range []string{DailyKind, WeeklyKind, MonthlyKind} {
+		if strings.EqualFold(args[i], kind) {
+			return kind, nil
+		}
+	}
+	return "", fmt.Errorf("unknown period %q, periods are daily, weekly and monthly", args[i])
+}
+
+// userReport keeps rows of the user in a detailed review report.
+func userReport(r *report.Report, user string) *report.Report {
+	sections := make([]report.Section, 0, len(r.Sections))
+	for _, section := range r.Sections {
+		rows := make([]report.Row, 0)
+		for _, row := range section.Rows {
+			if strings.EqualFold(row.Name, user) {
+				row.Rank = 0
+				rows = append(rows, row)
+			}
+		}
+		if len(rows) != 0 {
+			sections = append(sections, report.Section{Title: section.Title, Rows: rows})
+		}
+	}
+	return &report.Report{
+		Title:    fmt.Sprintf("%s - %s", r.Title, user),
+		Sections: sections,
+		Empty:    fmt.Sprintf("No reviews of %s 😢", user),
+		Start:    r.Start,
+		End:      r.End,
+	}
+}
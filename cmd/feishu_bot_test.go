@@ -0,0 +1,76 @@
+// Copyright 2021 ghstats Project Authors. Licensed under MIT.
+
+package cmd
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/overvenus/ghstats/pkg/config"
+	"github.com/overvenus/ghstats/pkg/feishu"
+)
+
+func TestParseBotCommand(t *testing.T) {
+	cases := []struct {
+		text string
+		name string
+		args []string
+	}{
+		{text: "/ptal", name: "ptal", args: []string{}},
+		{text: "  /PTAL  tidb ", name: "ptal", args: []string{"tidb"}},
+		{text: "@bot /stats @alice weekly", name: "stats", args: []string{"alice", "weekly"}},
+		{text: "@bot @other /review monthly", name: "review", args: []string{"monthly"}},
+		{text: "/stats\t@alice\nmonthly", name: "stats", args: []string{"alice", "monthly"}},
+		{text: "hello /ptal"},
+		{text: "@bot hello"},
+		{text: "@bot"},
+		{text: ""},
+	}
+	for _, c := range cases {
+		name, args := parseBotCommand(c.text)
+		if name != c.name || !reflect.DeepEqual(args, c.args) {
+			t.Errorf("%q: expect %q %q, got %q %q", c.text, c.name, c.args, name, args)
+		}
+	}
+}
+
+func TestMessageCommand(t *testing.T) {
+	users := []config.User{{GitHub: "alice", FeishuOpenID: "ou_alice"}}
+	cases := []struct {
+		content  string
+		mentions []feishu.Mention
+		name     string
+		args     []string
+	}{
+		// Display names may contain spaces.
+		{
+			content: `{"text":"@_user_1 /stats @_user_2 weekly"}`,
+			mentions: []feishu.Mention{
+				{Key: "@_user_1", Name: "GH Stats Bot", ID: feishu.UserID{OpenID: "ou_bot"}},
+				{Key: "@_user_2", Name: "Alice Liddell", ID: feishu.UserID{OpenID: "ou_alice"}},
+			},
+			name: "stats",
+			args: []string{"alice", "weekly"},
+		},
+		// Unmapped users are dropped.
+		{
+			content: `{"text":"@_user_1 /stats @_user_2"}`,
+			mentions: []feishu.Mention{
+				{Key: "@_user_1", Name: "GH Stats Bot", ID: feishu.UserID{OpenID: "ou_bot"}},
+				{Key: "@_user_2", Name: "Bob Smith", ID: feishu.UserID{OpenID: "ou_bob"}},
+			},
+			name: "stats",
+			args: []string{},
+		},
+	}
+	for _, c := range cases {
+		msg := feishu.Message{MessageType: "text", Content: c.content, Mentions: c.mentions}
+		name, args, err := messageCommand(msg, users)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if name != c.name || !reflect.DeepEqual(args, c.args) {
+			t.Errorf("%q: expect %q %q, got %q %q", c.content, c.name, c.args, name, args)
+		}
+	}
+}
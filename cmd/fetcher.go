@@ -0,0 +1,83 @@
+// Copyright 2021 ghstats Project Authors. Licensed under MIT.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/google/go-github/v35/github"
+	"github.com/overvenus/ghstats/pkg/config"
+	"github.com/overvenus/ghstats/pkg/gh"
+	"github.com/overvenus/ghstats/pkg/store"
+	"github.com/spf13/cobra"
+	"golang.org/x/oauth2"
+)
+
+const fromStoreFlag = "from-store"
+
+func addFromStoreFlag(command *cobra.Command) {
+	command.PersistentFlags().Bool(fromStoreFlag, false,
+		"Compute reports from the local store filled by the sync command")
+}
+
+// newGithubHTTPClient returns an authorized HTTP client for GitHub,
+// responses are cached for the lifetime of the client.
+func newGithubHTTPClient(ctx context.Context, cfg config.GitHub, token string) *http.Client {
+	httpClient := oauth2.NewClient(ctx, oauth2.StaticTokenSource(
+		&oauth2.Token{AccessToken: token},
+	))
+	cacheDir := ""
+	if cfg.Cache {
+		cacheDir = cfg.CacheDir
+	}
+	httpClient.Transport = gh.NewCacheTransport(httpClient.Transport, cacheDir)
+	return httpClient
+}
+
+func newGithubClient(ctx context.Context, cfg config.GitHub, token string) *github.Client {
+	return github.NewClient(newGithubHTTPClient(ctx, cfg, token))
+}
+
+// newGithubFetcher returns a fetcher of the configured GitHub API backend.
+func newGithubFetcher(ctx context.Context, cfg config.GitHub, token string) (gh.Fetcher, error) {
+	httpClient := newGithubHTTPClient(ctx, cfg, token)
+	rest := gh.NewFetcher(github.NewClient(httpClient))
+	switch cfg.Backend {
+	case "", "rest":
+		return rest, nil
+	case "graphql":
+		return gh.NewGraphQLFetcher(httpClient, rest), nil
+	default:
+		return nil, fmt.Errorf("unknown github backend %q", cfg.Backend)
+	}
+}
+
+// newFetcher returns a fetcher of GitHub resources, it reads from the
+// local store if --from-store is set.
+// The returned function must be called to release resources.
+func newFetcher(
+	ctx context.Context, cmd *cobra.Command, cfg *config.Config, token string,
+) (gh.Fetcher, func(), error) {
+	fromStore := false
+	if cmd.Flags().Lookup(fromStoreFlag) != nil {
+		var err error
+		fromStore, err = cmd.Flags().GetBool(fromStoreFlag)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	if fromStore {
+		s, err := store.Open(cfg.Store.Path, true)
+		if err != nil {
+			return nil, nil, err
+		}
+		return s, func() { s.Close() }, nil
+	}
+	fetcher, err := newGithubFetcher(ctx, cfg.GitHub, token)
+	if err != nil {
+		return nil, nil, err
+	}
+	return fetcher, func() {}, nil
+}
@@ -0,0 +1,55 @@
+// Copyright 2021 ghstats Project Authors. Licensed under MIT.
+
+package cmd
+
+import (
+	"fmt"
+	"path"
+
+	"github.com/google/go-github/v35/github"
+)
+
+// labelFilter filters issues and PRs by labels. Labels are matched by
+// patterns in path.Match syntax, e.g. "component/*".
+type labelFilter struct {
+	// If it is not empty, issues must have at least one of these labels.
+	allow []string
+	// Issues must not have any of these labels.
+	block []string
+}
+
+// isBlocked returns whether an issue or PR with the labels is filtered out.
+func (f labelFilter) isBlocked(labels []*github.Label) bool {
+	return len(f.blockedReason(labels)) != 0
+}
+
+// blockedReason returns why an issue or PR with the labels is filtered
+// out, it is empty if it is not.
+func (f labelFilter) blockedReason(labels []*github.Label) string {
+	if len(f.allow) > 0 && !hasLabel(labels, f.allow) {
+		return fmt.Sprintf("no label matches allow-labels %q", f.allow)
+	}
+	if label, pattern, ok := matchLabel(labels, f.block); ok {
+		return fmt.Sprintf("label %q matches block-labels %q", label, pattern)
+	}
+	return ""
+}
+
+// hasLabel returns whether any label matches any pattern.
+func hasLabel(labels []*github.Label, patterns []string) bool {
+	_, _, ok := matchLabel(labels, patterns)
+	return ok
+}
+
+// matchLabel returns the first label that matches any pattern and the
+// pattern.
+func matchLabel(labels []*github.Label, patterns []string) (string, string, bool) {
+	for _, label := range labels {
+		for _, pattern := range patterns {
+			if matched, _ := path.Match(pattern, label.GetName()); matched {
+				return label.GetName(), pattern, true
+			}
+		}
+	}
+	return "", "", false
+}
@@ -0,0 +1,254 @@
+// Copyright 2021 ghstats Project Authors. Licensed under MIT.
+
+package cmd
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/overvenus/ghstats/pkg/config"
+	"github.com/overvenus/ghstats/pkg/gh"
+	"github.com/overvenus/ghstats/pkg/metrics"
+	log "github.com/sirupsen/logrus"
+)
+
+// Upper bounds of PR age buckets in seconds, from 1 hour to 30 days.
+var prAgeBuckets = []float64{
+	3600, 6 * 3600, 24 * 3600, 3 * 24 * 3600, 7 * 24 * 3600, 14 * 24 * 3600, 30 * 24 * 3600,
+}
+
+// metricsExporter collects review and PTAL metrics periodically.
+type metricsExporter struct {
+	cfg      *config.Config
+	days     int
+	registry *metrics.Registry
+
+	mu sync.Mutex
+	// The latest collected families, they are served until the next
+	// collection succeeds.
+	families     []metrics.Family
+	runs         int
+	failures     int
+	lastSuccess  time.Time
+	lastDuration time.Duration
+}
+
+func newMetricsExporter(cfg *config.Config, days int) *metricsExporter {
+	return &metricsExporter{cfg: cfg, days: days, registry: &metrics.Registry{}}
+}
+
+// run collects metrics every interval until ctx is done.
+func (e *metricsExporter) run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		e.collectOnce(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (e *metricsExporter) collectOnce(ctx context.Context) {
+	start := time.Now()
+	families, err := e.collect(ctx)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.runs++
+	e.lastDuration = time.Since(start)
+	if err != nil {
+		e.failures++
+		log.Errorf("collect metrics failed: %v", err)
+	} else {
+		e.families = families
+		e.lastSuccess = time.Now()
+		log.Infof("collect metrics done in %s", e.lastDuration)
+	}
+	families = append([]metrics.Family{}, e.families...)
+	e.registry.Set(append(families, e.selfFamilies()...))
+}
+
+// selfFamilies returns metrics of collections.
+func (e *metricsExporter) selfFamilies() []metrics.Family {
+	runs := newFamily("ghstats_collect_runs_total", "Number of metric collections.", metrics.Counter)
+	runs.Add(nil, float64(e.runs))
+	failures := newFamily("ghstats_collect_failures_total", "Number of failed metric collections.", metrics.Counter)
+	failures.Add(nil, float64(e.failures))
+	duration := newFamily("ghstats_collect_duration_seconds", "Duration of the last metric collection.", metrics.Gauge)
+	duration.Add(nil, e.lastDuration.Seconds())
+	success := newFamily("ghstats_collect_last_success_timestamp_seconds",
+		"Unix time of the last successful metric collection.", metrics.Gauge)
+	if !e.lastSuccess.IsZero() {
+		success.Add(nil, float64(e.lastSuccess.Unix()))
+	}
+	return []metrics.Family{runs, failures, duration, success}
+}
+
+func newFamily(name, help string, typ metrics.Type) metrics.Family {
+	return metrics.Family{Name: name, Help: help, Type: typ}
+}
+
+// collect collects all metrics, clients are created for each collection
+// so that responses are not cached across collections.
+func (e *metricsExporter) collect(ctx context.Context) ([]metrics.Family, error) {
+	end := time.Now().In(timeZone)
+	start := end.AddDate(0, 0, -e.days)
+
+	families := make([]metrics.Family, 0)
+	if len(e.cfg.Review.Repos) != 0 {
+		fetcher, err := newGithubFetcher(ctx, e.cfg.GitHub, e.cfg.Review.GithubToken)
+		if err != nil {
+			return nil, err
+		}
+		reviews, _, err := collectReviewRange(ctx, fetcher, e.cfg.Review, "Metrics", start, end)
+		if err != nil {
+			return nil, err
+		}
+		families = append(families, reviewFamilies(reviews, e.cfg.Review)...)
+	}
+	if len(e.cfg.PTAL.Repos) != 0 {
+		fetcher, err := newGithubFetcher(ctx, e.cfg.GitHub, e.cfg.PTAL.GithubToken)
+		if err != nil {
+			return nil, err
+		}
+		ptal, err := ptalFamilies(ctx, fetcher, e.cfg.PTAL, end)
+		if err != nil {
+			return nil, err
+		}
+		families = append(families, ptal...)
+	}
+
+	token := e.cfg.Review.GithubToken
+	if len(token) == 0 {
+		token = e.cfg.PTAL.GithubToken
+	}
+	client := newGithubClient(ctx, e.cfg.GitHub, token)
+	limits, _, err := client.RateLimits(ctx)
+	if err != nil {
+		return nil, err
+	}
+	remaining := newFamily("ghstats_github_rate_limit_remaining",
+		"Remaining GitHub API requests of the current rate limit window.", metrics.Gauge)
+	limit := newFamily("ghstats_github_rate_limit", "GitHub API requests allowed in a rate limit window.", metrics.Gauge)
+	if limits.Core != nil {
+		remaining.Add(metrics.Labels{"resource": "core"}, float64(limits.Core.Remaining))
+		limit.Add(metrics.Labels{"resource": "core"}, float64(limits.Core.Limit))
+	}
+	if limits.Search != nil {
+		remaining.Add(metrics.Labels{"resource": "search"}, float64(limits.Search.Remaining))
+		limit.Add(metrics.Labels{"resource": "search"}, float64(limits.Search.Limit))
+	}
+	return append(families, remaining, limit), nil
+}
+
+// reviewFamilies returns review activities of each user in each repo and
+// scores of users.
+func reviewFamilies(reviews map[string]review, cfg config.Review) []metrics.Family {
+	activities := newFamily("ghstats_review_activities",
+		"Review activities of users within the collection window.", metrics.Gauge)
+	score := newFamily("ghstats_review_score", "ReviewBoard score of users within the collection window.", metrics.Gauge)
+	users := make([]string, 0, len(reviews))
+	for user := range reviews {
+		users = append(users, user)
+	}
+	sort.Strings(users)
+	for _, user := range users {
+		r := reviews[user]
+		if r.total() == 0 {
+			continue
+		}
+		byRepo := make(map[string]review)
+		for url, counts := range r.issues {
+			repo, _ := splitIssueURL(url)
+			sum := byRepo[repo]
+			sum.add(counts)
+			byRepo[repo] = sum
+		}
+		repos := make([]string, 0, len(byRepo))
+		for repo := range byRepo {
+			repos = append(repos, repo)
+		}
+		sort.Strings(repos)
+		for _, repo := range repos {
+			counts := byRepo[repo]
+			for kind, n := range map[string]int{
+				"lgtm":          counts.prLGTMs,
+				"pr_comment":    counts.prComments,
+				"issue_comment": counts.issueComments,
+				"issue_create":  counts.issueCreates,
+				"label_add":     counts.labelAdds,
+				"triage":        counts.triages,
+			} {
+				if n != 0 {
+					activities.Add(metrics.Labels{"user": user, "repo": repo, "kind": kind}, float64(n))
+				}
+			}
+		}
+		score.Add(metrics.Labels{"user": user}, r.score(cfg.Weights, cfg.Caps))
+	}
+	sort.SliceStable(activities.Samples, func(i, j int) bool {
+		a, b := activities.Samples[i].Labels, activities.Samples[j].Labels
+		if a["user"] != b["user"] {
+			return a["user"] < b["user"]
+		}
+		if a["repo"] != b["repo"] {
+			return a["repo"] < b["repo"]
+		}
+		return a["kind"] < b["kind"]
+	})
+	return []metrics.Family{activities, score}
+}
+
+// ptalFamilies returns the number and ages of PRs that need to be
+// reviewed in each repo.
+func ptalFamilies(
+	ctx context.Context, fetcher gh.Fetcher, cfg config.PTAL, now time.Time,
+) ([]metrics.Family, error) {
+	open := newFamily("ghstats_ptal_open_prs", "PRs that need to be reviewed.", metrics.Gauge)
+	age := newFamily("ghstats_ptal_pr_age_seconds", "Ages of PRs that need to be reviewed, since they are ready for review.", metrics.Histogram)
+	filter, err := newPTALFilter(cfg)
+	if err != nil {
+		return nil, err
+	}
+	for _, proj := range cfg.Repos {
+		labels := labelFilter{allow: proj.AllowLabels, block: proj.BlockLabels}
+		ages := metrics.NewBuckets(prAgeBuckets)
+		seen := make(map[string]bool)
+		for _, query := range proj.PRQuery {
+			results, err := fetcher.SearchIssues(ctx, query)
+			if err != nil {
+				return nil, err
+			}
+			for _, res := range results {
+				for _, issue := range res.Issues {
+					if !issue.IsPullRequest() || seen[issue.GetHTMLURL()] || filter.skipIssue(labels, issue) {
+						continue
+					}
+					owner, repo := gh.GetRepository(issue)
+					pr, err := fetcher.PullRequestsGet(ctx, owner, repo, issue.GetNumber())
+					if err != nil {
+						return nil, err
+					}
+					if filter.skipPR(pr) {
+						continue
+					}
+					seen[issue.GetHTMLURL()] = true
+					// Ages are measured as in PTAL reports.
+					ready, err := readyForReviewAt(ctx, fetcher, pr)
+					if err != nil {
+						return nil, err
+					}
+					ages.Observe(now.Sub(ready).Seconds())
+				}
+			}
+		}
+		open.Add(metrics.Labels{"repo": proj.Name}, float64(len(seen)))
+		age.AddHistogram(metrics.Labels{"repo": proj.Name}, ages)
+	}
+	return []metrics.Family{open, age}, nil
+}
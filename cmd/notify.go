@@ -0,0 +1,22 @@
+// Copyright 2021 ghstats Project Authors. Licensed under MIT.
+
+package cmd
+
+import (
+	"github.com/overvenus/ghstats/pkg/config"
+	"github.com/overvenus/ghstats/pkg/notify"
+)
+
+// newPTALNotifier returns the notifier of PTAL and pkgs reports.
+func newPTALNotifier(cfg *config.Config) (notify.Notifier, error) {
+	return notify.FromConfig(cfg.PTAL.Notifiers, cfg.PTAL.Notifier, cfg.PTAL.Access, notifyOptions(cfg))
+}
+
+// newReviewNotifier returns the notifier of review reports.
+func newReviewNotifier(cfg *config.Config) (notify.Notifier, error) {
+	return notify.FromConfig(cfg.Review.Notifiers, cfg.Review.Notifier, cfg.Review.Access, notifyOptions(cfg))
+}
+
+func notifyOptions(cfg *config.Config) notify.Options {
+	return notify.Options{IsTest: cfg.IsOnlyPrintMsg, Feishu: cfg.Feishu, Users: cfg.Users}
+}
@@ -0,0 +1,91 @@
+// Copyright 2021 ghstats Project Authors. Licensed under MIT.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/overvenus/ghstats/pkg/report"
+	"github.com/spf13/cobra"
+)
+
+const (
+	outputFlag     = "output"
+	outputFileFlag = "output-file"
+)
+
+func addOutputFlags(command *cobra.Command) {
+	command.PersistentFlags().String(outputFlag, "",
+		"Write the report in json, csv, markdown, html or table format instead of sending it")
+	command.PersistentFlags().String(outputFileFlag, "",
+		"Write the report to the file instead of stdout, used with --output")
+}
+
+// output writes reports in the format of --output.
+type output struct {
+	format string
+	path   string
+}
+
+// getOutput returns the output of the command, format is empty if reports
+// are sent by notifiers.
+func getOutput(cmd *cobra.Command) (output, error) {
+	format, err := cmd.Flags().GetString(outputFlag)
+	if err != nil {
+		return output{}, err
+	}
+	path, err := cmd.Flags().GetString(outputFileFlag)
+	if err != nil {
+		return output{}, err
+	}
+	switch format {
+	case "", "json", "csv", "markdown", "html", "table":
+	default:
+		return output{}, fmt.Errorf("unknown output format %q, must be json, csv, markdown, html or table", format)
+	}
+	return output{format: format, path: path}, nil
+}
+
+// detailed returns whether reports should carry all counters and per
+// issue rows, which are too long for chat messages.
+func (o output) detailed() bool {
+	return len(o.format) != 0
+}
+
+func (o output) write(r *report.Report) error {
+	if len(o.path) == 0 {
+		return o.writeTo(os.Stdout, r)
+	}
+	f, err := os.Create(o.path)
+	if err != nil {
+		return err
+	}
+	err = o.writeTo(f, r)
+	if err1 := f.Close(); err == nil {
+		err = err1
+	}
+	return err
+}
+
+func (o output) writeTo(w io.Writer, r *report.Report) error {
+	switch o.format {
+	case "json":
+		b, err := report.JSON(r)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s\n", b)
+		return err
+	case "csv":
+		return report.WriteCSV(w, r)
+	case "html":
+		return report.WriteHTML(w, r)
+	case "table":
+		return report.WriteTable(w, r)
+	default:
+		_, err := io.WriteString(w, report.Markdown(r))
+		return err
+	}
+}
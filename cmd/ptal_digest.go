@@ -0,0 +1,273 @@
+// Copyright 2021 ghstats Project Authors. Licensed under MIT.
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/overvenus/ghstats/pkg/config"
+	"github.com/overvenus/ghstats/pkg/gh"
+	"github.com/overvenus/ghstats/pkg/notify"
+	"github.com/overvenus/ghstats/pkg/report"
+	log "github.com/sirupsen/logrus"
+)
+
+// notifyReviewers sends each reviewer a digest of PRs waiting on them,
+// see reviewerResolver. r must be the full report, see ptalFullReport, so
+// that digests are not limited by ptalMaxRows. Reviewers who could not be
+// notified are skipped.
+func notifyReviewers(ctx context.Context, cfg *config.Config, fetcher gh.Fetcher, r *report.Report) error {
+	notifier, err := notify.NewUserNotifier(notifyOptions(cfg))
+	if err != nil {
+		return err
+	}
+	rows := make([]report.Row, 0)
+	for _, section := range r.Sections {
+		rows = append(rows, section.Rows...)
+	}
+	resolver := newReviewerResolver(fetcher, cfg.Users)
+	waiting := make([][]string, len(rows))
+	err = parallel(ctx, ptalConcurrency, len(rows), func(ctx context.Context, i int) error {
+		logins, err := resolver.waitingOn(ctx, rows[i].URL)
+		waiting[i] = logins
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	reviewers := make(map[string][]string, len(rows))
+	for i, row := range rows {
+		reviewers[row.URL] = waiting[i]
+	}
+
+	logins, digests := reviewerDigests(r, reviewers)
+	errs := make([]string, 0)
+	for _, login := range logins {
+		err := notifier.NotifyUser(ctx, login, notify.Message{Report: digests[login], Severity: notify.SeverityInfo})
+		if errors.Is(err, notify.ErrUnknownUser) {
+			log.Warnf("skip PTAL digest of %s: %v", login, err)
+			continue
+		}
+		if err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) != 0 {
+		return fmt.Errorf("send PTAL digests failed: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// reviewerDigests splits the report by reviewers of rows, which are keyed
+// by URLs of rows. It returns sorted logins and the digest of each of
+// them, e.g. "3 PRs are waiting on you, oldest 4d2h". Rows do not mention
+// anyone in digests as they are sent in person.
+func reviewerDigests(r *report.Report, reviewers map[string][]string) ([]string, map[string]*report.Report) {
+	digests := make(map[string]*report.Report)
+	oldest := make(map[string]time.Duration)
+	count := make(map[string]int)
+	logins := make([]string, 0)
+	for _, section := range r.Sections {
+		for _, row := range section.Rows {
+			for _, login := range reviewers[row.URL] {
+				d, ok := digests[login]
+				if !ok {
+					d = &report.Report{Start: r.Start, End: r.End}
+					digests[login] = d
+					logins = append(logins, login)
+				}
+				if n := len(d.Sections); n == 0 || d.Sections[n-1].Title != section.Title {
+					d.Sections = append(d.Sections, report.Section{Title: section.Title})
+				}
+				row := row
+				row.Mentions = nil
+				last := &d.Sections[len(d.Sections)-1]
+				last.Rows = append(last.Rows, row)
+				count[login]++
+				if age := rowAge(row); age > oldest[login] {
+					oldest[login] = age
+				}
+			}
+		}
+	}
+	for login, d := range digests {
+		waiting := fmt.Sprintf("%d PRs are waiting on you", count[login])
+		if count[login] == 1 {
+			waiting = "1 PR is waiting on you"
+		}
+		d.Title = fmt.Sprintf("%s - %s, oldest %s", r.Title, waiting, report.FormatDuration(oldest[login]))
+	}
+	sort.Strings(logins)
+	return logins, digests
+}
+
+// rowAge returns the age metric of a PTAL row.
+func rowAge(row report.Row) time.Duration {
+	for _, m := range row.Metrics {
+		if m.Name == "age" {
+			return time.Duration(m.Value * float64(time.Second))
+		}
+	}
+	return 0
+}
+
+// reviewerResolver resolves who PRs are waiting on, they are requested
+// reviewers, members of requested teams and code owners of changed files
+// who have not reviewed yet, except authors. CODEOWNERS and members of
+// teams are cached.
+type reviewerResolver struct {
+	fetcher gh.Fetcher
+	// GitHub logins by lower case emails, for email owners in CODEOWNERS.
+	emails map[string]string
+
+	mu         sync.Mutex
+	codeOwners map[string]*resolverEntry
+	teams      map[string]*resolverEntry
+}
+
+// resolverEntry is a cached value that is fetched once. Callers of the
+// same key wait for the first fetch, while callers of other keys go on.
+type resolverEntry struct {
+	once       sync.Once
+	codeOwners gh.CodeOwners
+	logins     []string
+}
+
+// entry returns the entry of the key in m, it is added if it is absent.
+func (r *reviewerResolver) entry(m map[string]*resolverEntry, key string) *resolverEntry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e, ok := m[key]
+	if !ok {
+		e = &resolverEntry{}
+		m[key] = e
+	}
+	return e
+}
+
+func newReviewerResolver(fetcher gh.Fetcher, users []config.User) *reviewerResolver {
+	emails := make(map[string]string)
+	for _, u := range users {
+		if len(u.Email) != 0 {
+			emails[strings.ToLower(u.Email)] = u.GitHub
+		}
+	}
+	return &reviewerResolver{
+		fetcher:    fetcher,
+		emails:     emails,
+		codeOwners: make(map[string]*resolverEntry),
+		teams:      make(map[string]*resolverEntry),
+	}
+}
+
+// waitingOn returns logins of reviewers that the PR is waiting on.
+func (r *reviewerResolver) waitingOn(ctx context.Context, url string) ([]string, error) {
+	fullName, n := splitIssueURL(url)
+	parts := strings.SplitN(fullName, "/", 2)
+	number, err := strconv.Atoi(n)
+	if err != nil || len(parts) != 2 {
+		return nil, fmt.Errorf("%s is not a PR", url)
+	}
+	owner, repo := parts[0], parts[1]
+	pr, err := r.fetcher.PullRequestsGet(ctx, owner, repo, number)
+	if err != nil {
+		return nil, err
+	}
+
+	// Teams and code owners are waited on until they review, requested
+	// reviewers are waited on even if they reviewed, as they are requested
+	// again.
+	owners := make([]string, 0)
+	for _, team := range pr.RequestedTeams {
+		owners = appendLogins(owners, r.teamMembers(ctx, owner, team.GetSlug())...)
+	}
+	codeOwners := r.repoCodeOwners(ctx, owner, repo, pr.GetBase().GetRef())
+	if len(codeOwners) != 0 {
+		files, err := r.fetcher.PullRequestsListFiles(ctx, owner, repo, number)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			for _, o := range codeOwners.Owners(file.GetFilename()) {
+				owners = appendLogins(owners, r.ownerLogins(ctx, o)...)
+			}
+		}
+	}
+	if len(owners) != 0 {
+		reviews, err := r.fetcher.PullRequestsListReviews(ctx, owner, repo, number)
+		if err != nil {
+			return nil, err
+		}
+		reviewed := make([]string, 0, len(reviews))
+		for _, review := range reviews {
+			reviewed = appendLogins(reviewed, review.GetUser().GetLogin())
+		}
+		owners = removeLogins(owners, reviewed...)
+	}
+
+	logins := appendLogins(requestedReviewers(pr), owners...)
+	return removeLogins(logins, pr.GetUser().GetLogin()), nil
+}
+
+// ownerLogins returns logins of an owner in CODEOWNERS, which is @user,
+// @org/team or an email of a configured user.
+func (r *reviewerResolver) ownerLogins(ctx context.Context, owner string) []string {
+	if !strings.HasPrefix(owner, "@") {
+		if login, ok := r.emails[strings.ToLower(owner)]; ok {
+			return []string{login}
+		}
+		return nil
+	}
+	owner = strings.TrimPrefix(owner, "@")
+	if parts := strings.SplitN(owner, "/", 2); len(parts) == 2 {
+		return r.teamMembers(ctx, parts[0], parts[1])
+	}
+	return []string{owner}
+}
+
+// teamMembers returns logins of members of the team. Listing members
+// requires the read:org scope, teams are ignored if it fails.
+func (r *reviewerResolver) teamMembers(ctx context.Context, org, slug string) []string {
+	key := org + "/" + slug
+	e := r.entry(r.teams, key)
+	e.once.Do(func() {
+		users, err := r.fetcher.TeamsListMembers(ctx, org, slug)
+		if err != nil {
+			log.Warnf("ignore team %s: %v", key, err)
+		}
+		e.logins = make([]string, 0, len(users))
+		for _, u := range users {
+			e.logins = append(e.logins, u.GetLogin())
+		}
+	})
+	return e.logins
+}
+
+// repoCodeOwners returns CODEOWNERS of the repository at ref, it is empty
+// if there is no CODEOWNERS. Code owners are ignored if it fails to get
+// CODEOWNERS, e.g. the store does not have files.
+func (r *reviewerResolver) repoCodeOwners(ctx context.Context, owner, repo, ref string) gh.CodeOwners {
+	key := fmt.Sprintf("%s/%s@%s", owner, repo, ref)
+	e := r.entry(r.codeOwners, key)
+	e.once.Do(func() {
+		for _, path := range gh.CodeOwnersPaths {
+			content, err := r.fetcher.RepositoriesGetFile(ctx, owner, repo, path, ref)
+			if err != nil {
+				log.Warnf("ignore CODEOWNERS of %s: %v", key, err)
+				return
+			}
+			if len(content) != 0 {
+				e.codeOwners = gh.ParseCodeOwners(content)
+				return
+			}
+		}
+	})
+	return e.codeOwners
+}
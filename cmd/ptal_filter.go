@@ -0,0 +1,94 @@
+// Copyright 2021 ghstats Project Authors. Licensed under MIT.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+
+	"github.com/google/go-github/v35/github"
+	"github.com/overvenus/ghstats/pkg/config"
+)
+
+// defaultSkipTitles matches titles of PRs that are work in progress or
+// must not be merged, e.g. "[WIP] ddl: ..." and "DNM: ...", but not
+// "swipe".
+var defaultSkipTitles = []string{`(?i)\b(wip|dnm)\b`}
+
+// ptalFilter skips PRs that do not need to be reviewed, see
+// config.PTAL.SkipLabels and config.PTAL.SkipTitles. Skipped PRs are
+// recorded with reasons, so that they could be explained.
+type ptalFilter struct {
+	labels  []string
+	titles  []*regexp.Regexp
+	skipped []skippedPR
+}
+
+type skippedPR struct {
+	url    string
+	title  string
+	reason string
+}
+
+func newPTALFilter(cfg config.PTAL) (*ptalFilter, error) {
+	titles := cfg.SkipTitles
+	if titles == nil {
+		titles = defaultSkipTitles
+	}
+	f := &ptalFilter{labels: cfg.SkipLabels}
+	for _, t := range titles {
+		re, err := regexp.Compile(t)
+		if err != nil {
+			return nil, fmt.Errorf("invalid skip-titles %q: %v", t, err)
+		}
+		f.titles = append(f.titles, re)
+	}
+	return f, nil
+}
+
+// skipIssue returns whether the PR in search results is skipped by labels
+// of the repo, skip labels or its title.
+func (f *ptalFilter) skipIssue(labels labelFilter, issue *github.Issue) bool {
+	reason := labels.blockedReason(issue.Labels)
+	if len(reason) == 0 {
+		if label, pattern, ok := matchLabel(issue.Labels, f.labels); ok {
+			reason = fmt.Sprintf("label %q matches skip-labels %q", label, pattern)
+		}
+	}
+	if len(reason) == 0 {
+		for _, re := range f.titles {
+			if re.MatchString(issue.GetTitle()) {
+				reason = fmt.Sprintf("title matches skip-titles `%s`", re)
+				break
+			}
+		}
+	}
+	if len(reason) == 0 {
+		return false
+	}
+	f.skip(issue.GetHTMLURL(), issue.GetTitle(), reason)
+	return true
+}
+
+// skipPR returns whether the PR is skipped as it is a draft. Search
+// results do not tell drafts, so it is checked against the PR.
+func (f *ptalFilter) skipPR(pr *github.PullRequest) bool {
+	if !pr.GetDraft() {
+		return false
+	}
+	f.skip(pr.GetHTMLURL(), pr.GetTitle(), "draft")
+	return true
+}
+
+func (f *ptalFilter) skip(url, title, reason string) {
+	f.skipped = append(f.skipped, skippedPR{url: url, title: title, reason: reason})
+}
+
+// explain writes skipped PRs and why they are skipped.
+func (f *ptalFilter) explain(w io.Writer) {
+	fmt.Fprintf(w, "%d PRs are skipped\n", len(f.skipped))
+	for _, s := range f.skipped {
+		fmt.Fprintf(w, "%s %q: %s\n", s.url, s.title, s.reason)
+	}
+}
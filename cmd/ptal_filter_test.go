@@ -0,0 +1,155 @@
+// Copyright 2021 ghstats Project Authors. Licensed under MIT.
+
+package cmd
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-github/v35/github"
+	"github.com/overvenus/ghstats/pkg/config"
+)
+
+func TestPTALFilterSkipIssue(t *testing.T) {
+	issue := func(title string, labels ...string) *github.Issue {
+		i := &github.Issue{Title: github.String(title), HTMLURL: github.String("https://github.com/o/r/pull/1")}
+		for _, l := range labels {
+			i.Labels = append(i.Labels, &github.Label{Name: github.String(l)})
+		}
+		return i
+	}
+	cases := []struct {
+		name   string
+		cfg    config.PTAL
+		repo   labelFilter
+		issue  *github.Issue
+		skip   bool
+		reason string
+	}{
+		{name: "plain", issue: issue("ddl: fix add index")},
+		{
+			name:   "wip",
+			issue:  issue("[WIP] ddl: fix add index"),
+			skip:   true,
+			reason: "title matches skip-titles `(?i)\\b(wip|dnm)\\b`",
+		},
+		{
+			name:   "dnm",
+			issue:  issue("DNM: test ci"),
+			skip:   true,
+			reason: "title matches skip-titles `(?i)\\b(wip|dnm)\\b`",
+		},
+		{name: "wip in a word", issue: issue("ui: support swipe")},
+		{
+			name:   "label glob",
+			cfg:    config.PTAL{SkipLabels: []string{"do-not-merge/*"}},
+			issue:  issue("ddl: fix add index", "type/bug", "do-not-merge/hold"),
+			skip:   true,
+			reason: `label "do-not-merge/hold" matches skip-labels "do-not-merge/*"`,
+		},
+		{
+			name:  "label glob mismatch",
+			cfg:   config.PTAL{SkipLabels: []string{"do-not-merge/*"}},
+			issue: issue("ddl: fix add index", "do-not-merge"),
+		},
+		{
+			name:   "block labels of the repo",
+			repo:   labelFilter{block: []string{"status/*"}},
+			issue:  issue("ddl: fix add index", "status/can-merge"),
+			skip:   true,
+			reason: `label "status/can-merge" matches block-labels "status/*"`,
+		},
+		{
+			name:  "no skip titles",
+			cfg:   config.PTAL{SkipTitles: []string{}},
+			issue: issue("[WIP] ddl: fix add index"),
+		},
+		{
+			name:   "custom skip titles",
+			cfg:    config.PTAL{SkipTitles: []string{`^\[draft\]`}},
+			issue:  issue("[draft] ddl: fix add index"),
+			skip:   true,
+			reason: "title matches skip-titles `^\\[draft\\]`",
+		},
+		{
+			name:  "custom skip titles replace defaults",
+			cfg:   config.PTAL{SkipTitles: []string{`^\[draft\]`}},
+			issue: issue("[WIP] ddl: fix add index"),
+		},
+	}
+	for _, c := range cases {
+		f, err := newPTALFilter(c.cfg)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if skip := f.skipIssue(c.repo, c.issue); skip != c.skip {
+			t.Errorf("%s: expect skip %v, got %v", c.name, c.skip, skip)
+			continue
+		}
+		if !c.skip {
+			if len(f.skipped) != 0 {
+				t.Errorf("%s: expect no skipped PRs, got %v", c.name, f.skipped)
+			}
+			continue
+		}
+		if len(f.skipped) != 1 || f.skipped[0].reason != c.reason {
+			t.Errorf("%s: expect reason %q, got %v", c.name, c.reason, f.skipped)
+		}
+	}
+}
+
+func TestPTALFilterSkipPR(t *testing.T) {
+	f, err := newPTALFilter(config.PTAL{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ready := &github.PullRequest{Draft: github.Bool(false), Title: github.String("ddl: fix")}
+	if f.skipPR(ready) || f.skipPR(&github.PullRequest{}) {
+		t.Error("expect PRs that are not drafts are not skipped")
+	}
+	draft := &github.PullRequest{
+		Draft:   github.Bool(true),
+		Title:   github.String("ddl: fix"),
+		HTMLURL: github.String("https://github.com/o/r/pull/1"),
+	}
+	if !f.skipPR(draft) {
+		t.Error("expect drafts are skipped")
+	}
+	if len(f.skipped) != 1 || f.skipped[0].reason != "draft" {
+		t.Errorf("expect a skipped draft, got %v", f.skipped)
+	}
+}
+
+func TestPTALFilterConfig(t *testing.T) {
+	cases := []struct {
+		toml   string
+		titles int
+	}{
+		{toml: "[ptal]\n", titles: len(defaultSkipTitles)},
+		// An empty list turns title skipping off.
+		{toml: "[ptal]\nskip-titles = []\n", titles: 0},
+		{toml: "[ptal]\nskip-titles = ['^a', '^b']\n", titles: 2},
+	}
+	for _, c := range cases {
+		path := filepath.Join(t.TempDir(), "cfg.toml")
+		if err := ioutil.WriteFile(path, []byte(c.toml), 0644); err != nil {
+			t.Fatal(err)
+		}
+		cfg, err := config.ReadConfig(path)
+		if err != nil {
+			t.Fatalf("%q: %v", c.toml, err)
+		}
+		f, err := newPTALFilter(cfg.PTAL)
+		if err != nil {
+			t.Fatalf("%q: %v", c.toml, err)
+		}
+		if len(f.titles) != c.titles {
+			t.Errorf("%q: expect %d skip titles, got %d", c.toml, c.titles, len(f.titles))
+		}
+	}
+
+	if _, err := newPTALFilter(config.PTAL{SkipTitles: []string{"("}}); err == nil {
+		t.Error("expect an error of an invalid skip-titles regexp")
+	}
+}
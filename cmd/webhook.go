@@ -0,0 +1,141 @@
+// Copyright 2021 ghstats Project Authors. Licensed under MIT.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v35/github"
+	"github.com/overvenus/ghstats/pkg/config"
+	"github.com/overvenus/ghstats/pkg/notify"
+	"github.com/overvenus/ghstats/pkg/report"
+	log "github.com/sirupsen/logrus"
+)
+
+// webhookTimeout bounds the handling of a delivery, listing files of a
+// large PR may take several requests.
+const webhookTimeout = 5 * time.Minute
+
+// webhookHandler receives GitHub webhook deliveries and sends PRs that
+// touch allow-pkgs of the PTAL repos as soon as they are ready for review.
+type webhookHandler struct {
+	cfg *config.Config
+}
+
+func newWebhookHandler(cfg *config.Config) *webhookHandler {
+	return &webhookHandler{cfg: cfg}
+}
+
+// ServeHTTP implements http.Handler.
+func (h *webhookHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	payload, err := github.ValidatePayload(r, []byte(h.cfg.GitHub.WebhookSecret))
+	if err != nil {
+		log.Warnf("invalid webhook delivery %s: %v", github.DeliveryID(r), err)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	eventType := github.WebHookType(r)
+	event, err := github.ParseWebHook(eventType, payload)
+	if err != nil {
+		// Events that are not supported by go-github.
+		log.Debugf("ignore webhook %s of %s: %v", github.DeliveryID(r), eventType, err)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	switch e := event.(type) {
+	case *github.PullRequestEvent:
+		if !readyForReview(e) {
+			break
+		}
+		log.Infof("webhook %s: PR %s %s", github.DeliveryID(r), e.GetPullRequest().GetHTMLURL(), e.GetAction())
+		// GitHub expects a response within 10 seconds.
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), webhookTimeout)
+			defer cancel()
+			if err := h.handlePullRequest(ctx, e); err != nil {
+				log.Errorf("handle PR %s failed: %v", e.GetPullRequest().GetHTMLURL(), err)
+			}
+		}()
+		w.WriteHeader(http.StatusAccepted)
+		return
+	// Reviews and comments do not change whether a PR needs to be
+	// reviewed, they are acknowledged only.
+	case *github.PullRequestReviewEvent:
+		log.Debugf("webhook %s: review %s %s", github.DeliveryID(r), e.GetPullRequest().GetHTMLURL(), e.GetAction())
+	case *github.IssueCommentEvent:
+		log.Debugf("webhook %s: comment %s %s", github.DeliveryID(r), e.GetIssue().GetHTMLURL(), e.GetAction())
+	case *github.PingEvent:
+		log.Infof("webhook %s: ping, hook %d", github.DeliveryID(r), e.GetHookID())
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// readyForReview returns true if the PR is just opened or marked ready
+// for review, drafts are sent once they are ready.
+func readyForReview(e *github.PullRequestEvent) bool {
+	switch e.GetAction() {
+	case "opened", "reopened":
+		return !e.GetPullRequest().GetDraft()
+	case "ready_for_review":
+		return true
+	}
+	return false
+}
+
+// handlePullRequest sends the PR if it touches allow-pkgs of any
+// configured repo.
+func (h *webhookHandler) handlePullRequest(ctx context.Context, e *github.PullRequestEvent) error {
+	pr := e.GetPullRequest()
+	fullName := e.GetRepo().GetFullName()
+	for _, proj := range h.cfg.PTAL.Repos {
+		if !strings.EqualFold(proj.PROwnerRepo, fullName) {
+			continue
+		}
+		f, err := newGithubFetcher(ctx, h.cfg.GitHub, h.cfg.PTAL.GithubToken)
+		if err != nil {
+			return err
+		}
+		reason, err := pkgsSkipReason(f, proj, pr)
+		if err != nil {
+			return err
+		}
+		if len(reason) != 0 {
+			log.Infof("repo:%s %s, url:%s", proj.Name, reason, pr.GetHTMLURL())
+			continue
+		}
+
+		mentions, err := pkgsMentions(f, proj, pr)
+		if err != nil {
+			return err
+		}
+		notifier, err := newPTALNotifier(h.cfg)
+		if err != nil {
+			return err
+		}
+		r := &report.Report{
+			Title: fmt.Sprintf("%s PTAL Repos:[%s] ❤️ - New PR", h.cfg.PTAL.ReportName, proj.Name),
+			Sections: []report.Section{{
+				Title: proj.Name,
+				Rows: []report.Row{{
+					Ref:      fmt.Sprintf("#%d", pr.GetNumber()),
+					URL:      pr.GetHTMLURL(),
+					Title:    pr.GetTitle(),
+					Author:   pr.GetUser().GetLogin(),
+					Metrics:  []report.Metric{report.Count("size", pr.GetAdditions()+pr.GetDeletions())},
+					Mentions: mentions,
+				}},
+			}},
+		}
+		return notifier.Notify(ctx, notify.Message{Report: r, Severity: notify.SeverityInfo})
+	}
+	return nil
+}
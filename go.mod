@@ -5,7 +5,9 @@ go 1.13
 require (
 	github.com/google/go-github/v35 v35.2.0
 	github.com/pelletier/go-toml v1.9.1
+	github.com/robfig/cron/v3 v3.0.1
 	github.com/sirupsen/logrus v1.8.1
 	github.com/spf13/cobra v1.7.0
+	go.etcd.io/bbolt v1.3.6
 	golang.org/x/oauth2 v0.0.0-20210514164344-f6687ab2804c
 )
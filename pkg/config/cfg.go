@@ -3,21 +3,36 @@ package config
 import (
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/pelletier/go-toml"
 )
 
 const (
-	githubTokenEnvKey        = "GHSTATS_GITHUB_TOKEN"
-	feishuWebhookTokenEnvKey = "GHSTATS_FEISHU_WEBHOOK_TOKEN"
+	githubTokenEnvKey         = "GHSTATS_GITHUB_TOKEN"
+	feishuWebhookTokenEnvKey  = "GHSTATS_FEISHU_WEBHOOK_TOKEN"
+	feishuWebhookSecretEnvKey = "GHSTATS_FEISHU_WEBHOOK_SECRET"
+	slackWebhookURLEnvKey     = "GHSTATS_SLACK_WEBHOOK_URL"
+	githubWebhookSecretEnvKey = "GHSTATS_GITHUB_WEBHOOK_SECRET"
+	feishuVerificationEnvKey  = "GHSTATS_FEISHU_VERIFICATION_TOKEN"
+	feishuEncryptKeyEnvKey    = "GHSTATS_FEISHU_ENCRYPT_KEY"
+	feishuAppIDEnvKey         = "GHSTATS_FEISHU_APP_ID"
+	feishuAppSecretEnvKey     = "GHSTATS_FEISHU_APP_SECRET"
 )
 
 // Config contains configuration options.
 type Config struct {
 	PTAL           `toml:"ptal"` // ptal and pkgs all use this configure.
 	Review         `toml:"review"`
-	IsOnlyPrintMsg bool `toml:"print-msg-local"` // Check whether the message is only printed locally.
+	Store          `toml:"store"`
+	GitHub         `toml:"github"`
+	Daemon         `toml:"daemon"`
+	Feishu         `toml:"feishu"`
+	Users          []User     `toml:"users"`
+	Schedules      []Schedule `toml:"schedules"`
+	IsOnlyPrintMsg bool       `toml:"print-msg-local"` // Check whether the message is only printed locally.
 }
 
 // Access contains access token for services.
@@ -26,6 +41,10 @@ type Access struct {
 	GithubToken string `toml:"github-token"`
 	// Feishu webhook bot
 	FeishuWebhookToken string `toml:"feishu-webhook-token"`
+	// Secret of the Feishu webhook bot if signature verification is on
+	FeishuWebhookSecret string `toml:"feishu-webhook-secret"`
+	// Slack incoming webhook
+	SlackWebhookURL string `toml:"slack-webhook-url"`
 }
 
 // If the Access struct has no value, get it from the environment variables.
@@ -37,6 +56,12 @@ func (a *Access) getFromEnv() {
 	if len(a.FeishuWebhookToken) == 0 {
 		a.FeishuWebhookToken = os.Getenv(feishuWebhookTokenEnvKey)
 	}
+	if len(a.FeishuWebhookSecret) == 0 {
+		a.FeishuWebhookSecret = os.Getenv(feishuWebhookSecretEnvKey)
+	}
+	if len(a.SlackWebhookURL) == 0 {
+		a.SlackWebhookURL = os.Getenv(slackWebhookURLEnvKey)
+	}
 }
 
 // Repo contains configuration options for Repo in PTAL command.
@@ -45,6 +70,13 @@ type Repo struct {
 	PRQuery     []string `toml:"pr-query"`
 	Packages    []string `toml:"allow-pkgs"`
 	PROwnerRepo string   `toml:"pr-owner-repo"`
+	// Label patterns, e.g. "component/*", applied in addition to
+	// the ones of the command.
+	AllowLabels []string `toml:"allow-labels"`
+	BlockLabels []string `toml:"block-labels"`
+	// GitHub logins of owners of allow-pkgs, keyed by package, they are
+	// mentioned in PRs that change the packages.
+	PackageOwners map[string][]string `toml:"package-owners"`
 }
 
 // PTAL contains configuration options for PTAL command.
@@ -52,6 +84,27 @@ type PTAL struct {
 	Access     `toml:"access"`
 	ReportName string `toml:"report-name"`
 	Repos      []Repo `toml:"repos"`
+	// Where reports are sent, "feishu" or "slack".
+	// It is ignored if Notifiers is not empty.
+	Notifier  string    `toml:"notifier" default:"feishu"`
+	Notifiers []Sink    `toml:"notifiers"`
+	Staleness Staleness `toml:"staleness"`
+	// Drafts are always skipped. PRs with labels that match any of
+	// SkipLabels, e.g. "do-not-merge/*", or titles that match any regexp
+	// of SkipTitles are skipped too. SkipTitles defaults to WIP and DNM
+	// titles, set it to [] to skip none.
+	SkipLabels []string `toml:"skip-labels"`
+	SkipTitles []string `toml:"skip-titles"`
+}
+
+// Staleness contains thresholds of PRs waiting for review in working
+// days, 0 disables a threshold. PTAL reports are orange if any PR waits
+// longer than WarnDays, and red if any waits longer than EscalateDays.
+type Staleness struct {
+	WarnDays     float64 `toml:"warn-days" default:"2"`
+	EscalateDays float64 `toml:"escalate-days" default:"5"`
+	// GitHub logins that are mentioned on escalated PRs, e.g. team leads.
+	Leads []string `toml:"leads"`
 }
 
 func (ptal PTAL) ReposName() string {
@@ -63,13 +116,134 @@ func (ptal PTAL) ReposName() string {
 }
 
 type Review struct {
-	Access        `toml:"access"`
+	Access `toml:"access"`
+	// Where reports are sent, "feishu" or "slack".
+	// It is ignored if Notifiers is not empty.
+	Notifier      string   `toml:"notifier" default:"feishu"`
+	Notifiers     []Sink   `toml:"notifiers"`
 	Repos         []Repo   `toml:"repos"`
 	LGTMComments  []string `toml:"lgtm-comments"`
 	BlockComments []string `toml:"block-comments"`
 	AllowUsers    []string `toml:"allow-users"`
 	BlockUsers    []string `toml:"block-users"`
 	BlockLabels   []string `toml:"block-labels"`
+	AllowLabels   []string `toml:"allow-labels"`
+	// Issue event types credited as triage work, see
+	// https://docs.github.com/en/developers/webhooks-and-events/events/issue-event-types
+	// Defaults to labeled, unlabeled, assigned, milestoned, closed and reopened.
+	TriageEvents []string `toml:"triage-events"`
+	// How many issues and PRs are collected concurrently.
+	Concurrency int     `toml:"concurrency" default:"4"`
+	Weights     Weights `toml:"weights"`
+	Caps        Caps    `toml:"caps"`
+	// How many top reviewers are mentioned in the ReviewBoard.
+	MentionTop int `toml:"mention-top" default:"3"`
+}
+
+// Weights contains score weights of review metrics in the ReviewBoard.
+type Weights struct {
+	LGTM         float64 `toml:"lgtm" default:"2.0"`
+	PRComment    float64 `toml:"pr-comment" default:"1.0"`
+	IssueComment float64 `toml:"issue-comment" default:"1.0"`
+	IssueCreate  float64 `toml:"issue-create" default:"2.0"`
+	LabelAdd     float64 `toml:"label-add" default:"0.5"`
+	Triage       float64 `toml:"triage" default:"0.5"`
+}
+
+// Caps contains the max scored count of review metrics in one issue or PR,
+// so that comment spam does not dominate the ReviewBoard. 0 means no cap.
+type Caps struct {
+	LGTM         int `toml:"lgtm"`
+	PRComment    int `toml:"pr-comment"`
+	IssueComment int `toml:"issue-comment"`
+	IssueCreate  int `toml:"issue-create"`
+	LabelAdd     int `toml:"label-add"`
+	Triage       int `toml:"triage"`
+}
+
+// Store contains configuration options for the local event store,
+// see `gh sync`.
+type Store struct {
+	Path string `toml:"path" default:"ghstats.db"`
+}
+
+// GitHub contains configuration options for accessing GitHub.
+type GitHub struct {
+	// API used to collect issues and pull requests, "rest" or "graphql".
+	Backend string `toml:"backend" default:"rest"`
+	// Persist responses in CacheDir and revalidate them in later runs.
+	Cache bool `toml:"cache"`
+	// Defaults to ghstats in the user cache directory.
+	CacheDir string `toml:"cache-dir"`
+	// Secret of GitHub webhooks, deliveries are verified by it.
+	WebhookSecret string `toml:"webhook-secret"`
+}
+
+// Daemon contains configuration options for `gh daemon`.
+type Daemon struct {
+	// Last run, success and failure of each schedule.
+	StateFile string `toml:"state-file" default:"ghstats-daemon.json"`
+}
+
+// Schedule is a command run by `gh daemon`.
+type Schedule struct {
+	// Defaults to the command.
+	Name string `toml:"name"`
+	// Standard cron expression, e.g. "0 10 * * 1-4", or descriptors like "@daily".
+	Cron string `toml:"cron"`
+	// Arguments of gh, e.g. "review weekly".
+	Command string `toml:"command"`
+	// Configuration file of the command, defaults to the one of the daemon.
+	Config   string `toml:"config"`
+	TimeZone string `toml:"timezone" default:"Asia/Shanghai"`
+	// Run once on start if a run is missed while the daemon is down.
+	CatchUp bool `toml:"catch-up" default:"true"`
+}
+
+// Feishu contains configuration options of Feishu webhook bots and the
+// Feishu app that receives bot commands, see `gh serve --feishu`.
+type Feishu struct {
+	// Verification token and encrypt key of the event subscription.
+	VerificationToken string `toml:"verification-token"`
+	EncryptKey        string `toml:"encrypt-key"`
+	// Credentials of the app, required to send messages to users and
+	// chats, e.g. by `gh ptal --dm` and feishu-app sinks.
+	AppID     string `toml:"app-id"`
+	AppSecret string `toml:"app-secret"`
+	// Timeout of sending a message.
+	Timeout time.Duration `toml:"timeout" default:"10s"`
+	// How many times a message is retried on server errors and rate limits.
+	Retries int `toml:"retries" default:"3"`
+}
+
+// User maps a GitHub login to accounts of the user in chat tools, so that
+// the user can be mentioned.
+type User struct {
+	GitHub string `toml:"github"`
+	// The user is mentioned in Feishu by the first one that is set.
+	FeishuOpenID string `toml:"feishu-open-id"`
+	FeishuUserID string `toml:"feishu-user-id"`
+	Email        string `toml:"email"`
+	// Where reports to the user, e.g. PTAL digests, are sent. The Feishu
+	// app sends them to the user's Feishu account if it is not set.
+	Notifiers []Sink `toml:"notifiers"`
+}
+
+// Sink is a destination of reports.
+type Sink struct {
+	// Type of the sink, "feishu", "feishu-app", "slack" or "file".
+	Type   string `toml:"type"`
+	Enable bool   `toml:"enable" default:"true"`
+	// Feishu webhook bot token and secret, used by feishu sinks.
+	FeishuWebhookToken  string `toml:"feishu-webhook-token"`
+	FeishuWebhookSecret string `toml:"feishu-webhook-secret"`
+	// The chat that the Feishu app sends reports to, used by feishu-app
+	// sinks.
+	FeishuChatID string `toml:"feishu-chat-id"`
+	// Slack incoming webhook URL, used by slack sinks.
+	SlackWebhookURL string `toml:"slack-webhook-url"`
+	// Reports are appended to the file, used by file sinks.
+	Path string `toml:"path"`
 }
 
 // ReadConfig reads config for config file.
@@ -84,5 +258,27 @@ func ReadConfig(cfgPath string) (*Config, error) {
 	}
 	cfg.PTAL.Access.getFromEnv()
 	cfg.Review.Access.getFromEnv()
+	if len(cfg.GitHub.WebhookSecret) == 0 {
+		cfg.GitHub.WebhookSecret = os.Getenv(githubWebhookSecretEnvKey)
+	}
+	if len(cfg.Feishu.VerificationToken) == 0 {
+		cfg.Feishu.VerificationToken = os.Getenv(feishuVerificationEnvKey)
+	}
+	if len(cfg.Feishu.EncryptKey) == 0 {
+		cfg.Feishu.EncryptKey = os.Getenv(feishuEncryptKeyEnvKey)
+	}
+	if len(cfg.Feishu.AppID) == 0 {
+		cfg.Feishu.AppID = os.Getenv(feishuAppIDEnvKey)
+	}
+	if len(cfg.Feishu.AppSecret) == 0 {
+		cfg.Feishu.AppSecret = os.Getenv(feishuAppSecretEnvKey)
+	}
+	if cfg.GitHub.Cache && len(cfg.GitHub.CacheDir) == 0 {
+		dir, err := os.UserCacheDir()
+		if err != nil {
+			return nil, err
+		}
+		cfg.GitHub.CacheDir = filepath.Join(dir, "ghstats")
+	}
 	return cfg, nil
 }
@@ -0,0 +1,223 @@
+// Copyright 2021 ghstats Project Authors. Licensed under MIT.
+
+package feishu
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// openAPIURL is the base URL of Feishu open APIs.
+var openAPIURL = "https://open.feishu.cn/open-apis"
+
+// Response codes of the open APIs.
+const (
+	// The tenant access token is missing or expired.
+	codeTokenMissing = 99991661
+	codeTokenInvalid = 99991663
+	// The app or the API is rate limited.
+	codeAppRateLimited = 99991400
+	codeIMRateLimited  = 230020
+)
+
+// tokenRefreshAhead is how long before its expiration a tenant access
+// token is refreshed, so that it does not expire in flight.
+const tokenRefreshAhead = 5 * time.Minute
+
+// ReceiveIDType is the type of the ID of a message receiver.
+type ReceiveIDType string
+
+const (
+	// ReceiveOpenID is the open_id of a user in the app.
+	ReceiveOpenID ReceiveIDType = "open_id"
+	// ReceiveUserID is the user_id of a user in the tenant.
+	ReceiveUserID ReceiveIDType = "user_id"
+	// ReceiveEmail is the email of a user.
+	ReceiveEmail ReceiveIDType = "email"
+	// ReceiveChatID is the chat_id of a group chat that the app is in.
+	ReceiveChatID ReceiveIDType = "chat_id"
+)
+
+// App is a Feishu app that sends messages to users and chats with its
+// tenant access token. Unlike WebhookBot, it is not tied to one group.
+// The token is cached and refreshed before it expires, so an App should
+// be shared instead of copied.
+//
+// Source: https://open.feishu.cn/document/uAjLw4CM/ukTMukTMukTM/reference/im-v1/message/create
+type App struct {
+	ID     string
+	Secret string
+	IsTest bool // If it's true, we only print the message to local.
+	// Client sends requests, http.DefaultClient is used if it is nil.
+	Client *http.Client
+	// How many times a message is retried on server errors, rate limits
+	// and expired tokens.
+	Retries int
+
+	mu     sync.Mutex
+	token  string
+	expire time.Time
+}
+
+// SendCard sends an interactive card to the receiver, e.g. a user by
+// ReceiveOpenID or a chat by ReceiveChatID.
+func (app *App) SendCard(ctx context.Context, idType ReceiveIDType, id string, card *Card) error {
+	content, err := json.Marshal(card)
+	if err != nil {
+		return err
+	}
+	payload, err := json.Marshal(map[string]string{
+		"receive_id": id,
+		"msg_type":   "interactive",
+		"content":    string(content),
+	})
+	if err != nil {
+		return err
+	}
+	if app.IsTest {
+		buf := bytes.Buffer{}
+		json.Indent(&buf, content, "", "  ")
+		fmt.Printf("Print messages to %s %s locally only: %s\n", idType, id, buf.String())
+		return nil
+	}
+	api := fmt.Sprintf("%s/im/v1/messages?receive_id_type=%s", openAPIURL, url.QueryEscape(string(idType)))
+	return retry(ctx, app.Retries, func() (bool, error) {
+		token, code, err := app.cachedToken(ctx)
+		if err != nil {
+			return retryableCode(code), err
+		}
+		code, err = app.post(ctx, api, token, payload)
+		if code == codeTokenMissing || code == codeTokenInvalid {
+			app.resetToken(token)
+			return true, err
+		}
+		return retryableCode(code), err
+	})
+}
+
+// retryableCode returns true if the request fails before Feishu answers
+// with a code, or it is rate limited.
+func retryableCode(code int) bool {
+	return code < 0 || code == codeAppRateLimited || code == codeIMRateLimited ||
+		code == codeTooManyRequests
+}
+
+// SendCardSections sends sections to the receiver in one card, or in
+// numbered cards if they do not fit in one card.
+func (app *App) SendCardSections(
+	ctx context.Context, idType ReceiveIDType, id string,
+	title string, sections []CardSection, titleColor TitleColor,
+) error {
+	return sendCards(title, sections, titleColor, func(card *Card) error {
+		return app.SendCard(ctx, idType, id, card)
+	})
+}
+
+// TenantAccessToken returns the cached tenant access token, a new one is
+// requested if it is about to expire.
+//
+// Source: https://open.feishu.cn/document/ukTMukTMukTM/ukDNz4SO0MjL5QzM/auth-v3/auth/tenant_access_token_internal
+func (app *App) TenantAccessToken(ctx context.Context) (string, error) {
+	token, _, err := app.cachedToken(ctx)
+	return token, err
+}
+
+// cachedToken is TenantAccessToken that also returns the response code,
+// see do.
+func (app *App) cachedToken(ctx context.Context) (string, int, error) {
+	app.mu.Lock()
+	defer app.mu.Unlock()
+	if len(app.token) != 0 && time.Now().Add(tokenRefreshAhead).Before(app.expire) {
+		return app.token, 0, nil
+	}
+	payload, err := json.Marshal(map[string]string{"app_id": app.ID, "app_secret": app.Secret})
+	if err != nil {
+		return "", 0, err
+	}
+	res := struct {
+		Token  string `json:"tenant_access_token"`
+		Expire int    `json:"expire"`
+	}{}
+	api := openAPIURL + "/auth/v3/tenant_access_token/internal"
+	if code, err := app.do(ctx, api, "", payload, &res); err != nil {
+		return "", code, fmt.Errorf("feishu tenant access token error, %v", err)
+	}
+	app.token = res.Token
+	app.expire = time.Now().Add(time.Duration(res.Expire) * time.Second)
+	return app.token, 0, nil
+}
+
+// resetToken drops the cached token if it is still token, so that the
+// next request gets a new one.
+func (app *App) resetToken(token string) {
+	app.mu.Lock()
+	defer app.mu.Unlock()
+	if app.token == token {
+		app.token = ""
+	}
+}
+
+// post posts a message once, see do.
+func (app *App) post(ctx context.Context, api, token string, payload []byte) (int, error) {
+	code, err := app.do(ctx, api, token, payload, nil)
+	if err != nil {
+		return code, fmt.Errorf("feishu send message error, %v", err)
+	}
+	return code, nil
+}
+
+// do posts the payload to api and decodes the response into res if it
+// is not nil. It returns the response code, which is -1 if the request
+// fails before Feishu answers with a code, e.g. a network error or a
+// server error.
+func (app *App) do(ctx context.Context, api, token string, payload []byte, res interface{}) (int, error) {
+	client := app.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, api, bytes.NewReader(payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Add("Content-Type", "application/json; charset=utf-8")
+	if len(token) != 0 {
+		req.Header.Add("Authorization", "Bearer "+token)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return -1, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return -1, err
+	}
+	// Errors are answered with 4xx and a code in the body.
+	result := struct {
+		Code *int   `json:"code"`
+		Msg  string `json:"msg"`
+	}{}
+	if err := json.Unmarshal(body, &result); err != nil || result.Code == nil {
+		code := 0
+		if resp.StatusCode >= http.StatusInternalServerError || resp.StatusCode == http.StatusTooManyRequests {
+			code = -1
+		}
+		return code, fmt.Errorf("[%d] %s", resp.StatusCode, string(body))
+	}
+	if *result.Code != 0 {
+		return *result.Code, fmt.Errorf("[code %d] %s", *result.Code, result.Msg)
+	}
+	if res != nil {
+		if err := json.Unmarshal(body, res); err != nil {
+			return 0, err
+		}
+	}
+	return 0, nil
+}
@@ -1,12 +1,17 @@
 package feishu
 
 import (
+	"bytes"
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"strings"
+	"time"
 )
 
 // TitleColor defines feishu message title color.
@@ -39,10 +44,28 @@ const (
 	TitleColorGrey TitleColor = "grey"
 )
 
+// Response codes that mean the bot is rate limited.
+const (
+	codeTooManyRequests = 9499
+	codeRateLimited     = 11232
+)
+
+// retryBackoff is the wait before the first retry, it doubles on
+// every retry.
+var retryBackoff = time.Second
+
 // WebhookBot is a feishu webhook bot.
 type WebhookBot struct {
 	Token  string
 	IsTest bool // If it's true, we only print the message to local.
+	// Secret of the "signature verification" security setting, messages
+	// are signed if it is set.
+	Secret string
+	// Client sends messages, http.DefaultClient is used if it is nil.
+	Client *http.Client
+	// How many times a message is retried on server errors and rate
+	// limits.
+	Retries int
 }
 
 // SendMarkdownMessage sends markdown message via feishu bot,
@@ -75,53 +98,116 @@ type WebhookBot struct {
 //
 // Source: https://open.feishu.cn/document/ukTMukTMukTM/ucTM5YjL3ETO24yNxkjN#4996824a
 func (bot WebhookBot) SendMarkdownMessage(ctx context.Context, title, msg string, titleColor TitleColor) error {
-	title1, err := json.Marshal(title)
-	if err != nil {
-		return err
+	return bot.SendCard(ctx, NewCard(title, titleColor).Add(Markdown(msg)))
+}
+
+// SendCard sends an interactive card via feishu bot.
+func (bot WebhookBot) SendCard(ctx context.Context, card *Card) error {
+	msg := map[string]interface{}{
+		"msg_type": "interactive",
+		"card":     card,
 	}
-	msg1, err := json.Marshal(msg)
+	if len(bot.Secret) != 0 {
+		timestamp := time.Now().Unix()
+		msg["timestamp"] = fmt.Sprint(timestamp)
+		msg["sign"] = Sign(timestamp, bot.Secret)
+	}
+	payload, err := json.Marshal(msg)
 	if err != nil {
 		return err
 	}
-	payload := fmt.Sprintf(`
-	{
-		"msg_type": "interactive",
-		"card": {
-			"config": {
-				"wide_screen_mode": true,
-				"enable_forward": true
-			},
-			"header": {
-				"title": {
-					"tag": "plain_text",
-					"content": %s
-				},
-				"template":"%s"
-			},
-			"elements": [
-				{
-					"tag": "div",
-					"text": {
-						"tag": "lark_md",
-						"content": %s
-					}
-				}
-			]
-		}
-	}`, title1, titleColor, string(msg1))
 	if bot.IsTest {
-		fmt.Printf("Print messages locally only: %s\n", payload)
+		buf := bytes.Buffer{}
+		json.Indent(&buf, payload, "", "  ")
+		fmt.Printf("Print messages locally only: %s\n", buf.String())
 		return nil
 	}
+	return bot.send(ctx, string(payload))
+}
+
+// Sign returns the signature of a message sent at timestamp, which is
+// base64(hmac-sha256(key: timestamp + "\n" + secret, message: "")).
+//
+// Source: https://open.feishu.cn/document/ukTMukTMukTM/ucTM5YjL3ETO24yNxkjN#348211be
+func Sign(timestamp int64, secret string) string {
+	h := hmac.New(sha256.New, []byte(fmt.Sprintf("%d\n%s", timestamp, secret)))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// send posts the payload, and retries with backoff if the server fails
+// or the bot is rate limited.
+func (bot WebhookBot) send(ctx context.Context, payload string) error {
+	return retry(ctx, bot.Retries, func() (bool, error) {
+		return bot.post(ctx, payload)
+	})
+}
+
+// retry calls fn until it succeeds, fails with a non-retryable error or
+// has been retried retries times, the backoff doubles on every retry.
+func retry(ctx context.Context, retries int, fn func() (bool, error)) error {
+	backoff := retryBackoff
+	for i := 0; ; i++ {
+		retryable, err := fn()
+		if err == nil || !retryable || i >= retries {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
 
+// post posts the payload once, it returns whether the error is retryable.
+func (bot WebhookBot) post(ctx context.Context, payload string) (bool, error) {
+	client := bot.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
 	url := fmt.Sprintf("https://open.feishu.cn/open-apis/bot/v2/hook/%s", bot.Token)
 	req, err := http.NewRequestWithContext(
 		ctx, http.MethodPost, url, strings.NewReader(payload))
+	if err != nil {
+		return false, err
+	}
 	req.Header.Add("Content-Type", "application/json")
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return true, err
+	}
 	if resp.StatusCode != http.StatusOK {
-		body, _ := ioutil.ReadAll(resp.Body)
-		return fmt.Errorf("feishu send markdown error [%d] %s", resp.StatusCode, string(body))
+		retryable := resp.StatusCode >= http.StatusInternalServerError ||
+			resp.StatusCode == http.StatusTooManyRequests
+		return retryable, fmt.Errorf("feishu send markdown error [%d] %s", resp.StatusCode, string(body))
+	}
+
+	// Responses are either {"code": 0, "msg": "success"} or the legacy
+	// {"StatusCode": 0, "StatusMessage": "success"}.
+	res := struct {
+		Code          *int   `json:"code"`
+		Msg           string `json:"msg"`
+		StatusCode    *int   `json:"StatusCode"`
+		StatusMessage string `json:"StatusMessage"`
+	}{}
+	if err := json.Unmarshal(body, &res); err != nil {
+		return false, fmt.Errorf("feishu send markdown error, invalid response %s", string(body))
+	}
+	code, msg := 0, res.Msg
+	if res.Code != nil {
+		code = *res.Code
+	} else if res.StatusCode != nil {
+		code, msg = *res.StatusCode, res.StatusMessage
+	}
+	if code != 0 {
+		retryable := code == codeTooManyRequests || code == codeRateLimited
+		return retryable, fmt.Errorf("feishu send markdown error [code %d] %s", code, msg)
 	}
-	return err
+	return false, nil
 }
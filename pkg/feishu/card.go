@@ -0,0 +1,138 @@
+// Copyright 2021 ghstats Project Authors. Licensed under MIT.
+
+package feishu
+
+import (
+	"encoding/json"
+)
+
+// Element is a card element, e.g. a div or a column set, build it with
+// Markdown, Fields, Columns, Divider, Note and Actions.
+//
+// Source: https://open.feishu.cn/document/ukTMukTMukTM/uEjNwUjLxYDM14SM2ATN
+type Element map[string]interface{}
+
+// Field is a text in a field list, short fields are laid out side by side.
+type Field struct {
+	Content string // lark_md
+	Short   bool
+}
+
+// Column is a column of a column set, it takes Weight parts of the width.
+type Column struct {
+	Weight   int
+	Elements []Element
+}
+
+// ButtonType is the style of a button.
+type ButtonType string
+
+const (
+	// ButtonDefault is a white button.
+	ButtonDefault ButtonType = "default"
+	// ButtonPrimary is a blue button.
+	ButtonPrimary ButtonType = "primary"
+)
+
+// Button opens the URL.
+type Button struct {
+	Text string
+	URL  string
+	Type ButtonType
+}
+
+func larkMD(content string) Element {
+	return Element{"tag": "lark_md", "content": content}
+}
+
+// Markdown returns a div of lark_md content.
+func Markdown(content string) Element {
+	return Element{"tag": "div", "text": larkMD(content)}
+}
+
+// Fields returns a div of fields.
+func Fields(fields ...Field) Element {
+	fs := make([]Element, 0, len(fields))
+	for _, f := range fields {
+		fs = append(fs, Element{"is_short": f.Short, "text": larkMD(f.Content)})
+	}
+	return Element{"tag": "div", "fields": fs}
+}
+
+// Columns returns a column set, columns are never stacked on narrow
+// screens.
+func Columns(columns ...Column) Element {
+	cs := make([]Element, 0, len(columns))
+	for _, c := range columns {
+		cs = append(cs, Element{
+			"tag":            "column",
+			"width":          "weighted",
+			"weight":         c.Weight,
+			"vertical_align": "top",
+			"elements":       c.Elements,
+		})
+	}
+	return Element{"tag": "column_set", "flex_mode": "none", "background_style": "default", "columns": cs}
+}
+
+// Divider returns a horizontal rule, like markdown.Separate in lark_md.
+func Divider() Element {
+	return Element{"tag": "hr"}
+}
+
+// Note returns a note of lark_md content in small grey text.
+func Note(content string) Element {
+	return Element{"tag": "note", "elements": []Element{larkMD(content)}}
+}
+
+// Actions returns buttons in a row.
+func Actions(buttons ...Button) Element {
+	actions := make([]Element, 0, len(buttons))
+	for _, b := range buttons {
+		typ := b.Type
+		if len(typ) == 0 {
+			typ = ButtonDefault
+		}
+		actions = append(actions, Element{
+			"tag":  "button",
+			"text": Element{"tag": "plain_text", "content": b.Text},
+			"url":  b.URL,
+			"type": typ,
+		})
+	}
+	return Element{"tag": "action", "actions": actions}
+}
+
+// Card is an interactive message card.
+type Card struct {
+	Title    string
+	Color    TitleColor
+	Elements []Element
+}
+
+// NewCard returns a card without elements.
+func NewCard(title string, color TitleColor) *Card {
+	return &Card{Title: title, Color: color}
+}
+
+// Add appends elements.
+func (c *Card) Add(elements ...Element) *Card {
+	c.Elements = append(c.Elements, elements...)
+	return c
+}
+
+// MarshalJSON implements json.Marshaler.
+func (c *Card) MarshalJSON() ([]byte, error) {
+	elements := c.Elements
+	if elements == nil {
+		elements = []Element{}
+	}
+	return json.Marshal(Element{
+		"config": Element{"wide_screen_mode": true, "enable_forward": true},
+		"header": Element{
+			"title":    Element{"tag": "plain_text", "content": c.Title},
+			"template": c.Color,
+		},
+		"elements": elements,
+	})
+}
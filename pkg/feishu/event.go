@@ -0,0 +1,240 @@
+// Copyright 2021 ghstats Project Authors. Licensed under MIT.
+
+package feishu
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// EventTypeURLVerification is sent when the request URL is configured.
+	EventTypeURLVerification = "url_verification"
+	// EventTypeMessageReceive is sent when the bot receives a message.
+	EventTypeMessageReceive = "im.message.receive_v1"
+)
+
+var (
+	// ErrInvalidToken is returned if the verification token mismatches.
+	ErrInvalidToken = errors.New("feishu event verification token mismatch")
+	// ErrInvalidSignature is returned if the signature mismatches or the
+	// event is too old.
+	ErrInvalidSignature = errors.New("feishu event signature mismatch")
+	// ErrUnencrypted is returned if the encrypt key is set but the event
+	// is not encrypted.
+	ErrUnencrypted = errors.New("feishu event is not encrypted")
+)
+
+// signatureMaxAge is how far the timestamp of a signed event could be
+// from now, so that captured events could not be replayed later.
+const signatureMaxAge = 5 * time.Minute
+
+// Event is a callback of the event subscription. Only url_verification
+// is sent in schema 1.0, other events are sent in schema 2.0.
+//
+// Source: https://open.feishu.cn/document/ukTMukTMukTM/uUTNz4SN1MjL1UzM
+type Event struct {
+	Schema string      `json:"schema"`
+	Header EventHeader `json:"header"`
+	// Event body, e.g. MessageEvent.
+	Event json.RawMessage `json:"event"`
+
+	// Fields of url_verification.
+	Type      string `json:"type"`
+	Token     string `json:"token"`
+	Challenge string `json:"challenge"`
+}
+
+// EventHeader is the header of schema 2.0 events.
+type EventHeader struct {
+	EventID    string `json:"event_id"`
+	EventType  string `json:"event_type"`
+	CreateTime string `json:"create_time"`
+	Token      string `json:"token"`
+	AppID      string `json:"app_id"`
+	TenantKey  string `json:"tenant_key"`
+}
+
+// EventType returns the type of the event in both schemas.
+func (e *Event) EventType() string {
+	if len(e.Type) != 0 {
+		return e.Type
+	}
+	return e.Header.EventType
+}
+
+// MessageEvent is the body of im.message.receive_v1.
+//
+// Source: https://open.feishu.cn/document/uAjLw4CM/ukTMukTMukTM/reference/im-v1/message/events/receive
+type MessageEvent struct {
+	Sender struct {
+		SenderID   UserID `json:"sender_id"`
+		SenderType string `json:"sender_type"`
+	} `json:"sender"`
+	Message Message `json:"message"`
+}
+
+// UserID contains IDs of a user.
+type UserID struct {
+	OpenID  string `json:"open_id"`
+	UserID  string `json:"user_id"`
+	UnionID string `json:"union_id"`
+}
+
+// Message is a received message.
+type Message struct {
+	MessageID   string    `json:"message_id"`
+	ChatID      string    `json:"chat_id"`
+	ChatType    string    `json:"chat_type"`
+	MessageType string    `json:"message_type"`
+	Content     string    `json:"content"`
+	Mentions    []Mention `json:"mentions"`
+}
+
+// Mention is a user mentioned in a message, it is written as Key,
+// e.g. "@_user_1", in the content.
+type Mention struct {
+	Key  string `json:"key"`
+	ID   UserID `json:"id"`
+	Name string `json:"name"`
+}
+
+// Text returns the text of a text message, mentions are replaced with
+// "@" and their names, or removed if their names are empty.
+func (m *Message) Text() (string, error) {
+	if m.MessageType != "text" {
+		return "", fmt.Errorf("unsupported message type %q", m.MessageType)
+	}
+	content := struct {
+		Text string `json:"text"`
+	}{}
+	if err := json.Unmarshal([]byte(m.Content), &content); err != nil {
+		return "", err
+	}
+	text := content.Text
+	for _, mention := range m.Mentions {
+		name := ""
+		if len(mention.Name) != 0 {
+			name = "@" + mention.Name
+		}
+		text = strings.Replace(text, mention.Key, name, -1)
+	}
+	return text, nil
+}
+
+// EventDecoder verifies and decodes event callbacks.
+type EventDecoder struct {
+	// Verification token of the app, events are not verified if it is empty.
+	VerificationToken string
+	// Encrypt key of the app. If it is set, events must be encrypted, and
+	// events except url_verification must be signed within signatureMaxAge.
+	EncryptKey string
+}
+
+// Decode decodes an event callback. If the encrypt key is set, the body is
+// decrypted, and the signature in headers is verified.
+func (d EventDecoder) Decode(header http.Header, body []byte) (*Event, error) {
+	encrypted := struct {
+		Encrypt string `json:"encrypt"`
+	}{}
+	if err := json.Unmarshal(body, &encrypted); err != nil {
+		return nil, err
+	}
+	plain := body
+	switch {
+	case len(d.EncryptKey) != 0 && len(encrypted.Encrypt) == 0:
+		return nil, ErrUnencrypted
+	case len(encrypted.Encrypt) != 0:
+		if len(d.EncryptKey) == 0 {
+			return nil, errors.New("feishu event is encrypted but the encrypt key is not set")
+		}
+		var err error
+		if plain, err = Decrypt(encrypted.Encrypt, d.EncryptKey); err != nil {
+			return nil, err
+		}
+	}
+
+	e := &Event{}
+	if err := json.Unmarshal(plain, e); err != nil {
+		return nil, err
+	}
+	// The request URL is verified before it is saved, so that the challenge
+	// is not signed.
+	if len(d.EncryptKey) != 0 && e.EventType() != EventTypeURLVerification {
+		if !validSignature(header, d.EncryptKey, body) ||
+			!freshTimestamp(header.Get("X-Lark-Request-Timestamp"), time.Now()) {
+			return nil, ErrInvalidSignature
+		}
+	}
+	token := e.Token
+	if len(token) == 0 {
+		token = e.Header.Token
+	}
+	if len(d.VerificationToken) != 0 &&
+		subtle.ConstantTimeCompare([]byte(token), []byte(d.VerificationToken)) != 1 {
+		return nil, ErrInvalidToken
+	}
+	return e, nil
+}
+
+// validSignature checks whether the signature equals to
+// sha256(timestamp + nonce + encrypt key + body).
+func validSignature(header http.Header, encryptKey string, body []byte) bool {
+	h := sha256.New()
+	h.Write([]byte(header.Get("X-Lark-Request-Timestamp")))
+	h.Write([]byte(header.Get("X-Lark-Request-Nonce")))
+	h.Write([]byte(encryptKey))
+	h.Write(body)
+	signature := hex.EncodeToString(h.Sum(nil))
+	return subtle.ConstantTimeCompare([]byte(signature), []byte(header.Get("X-Lark-Signature"))) == 1
+}
+
+// freshTimestamp returns true if the timestamp in seconds is within
+// signatureMaxAge from now.
+func freshTimestamp(timestamp string, now time.Time) bool {
+	sec, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	d := now.Sub(time.Unix(sec, 0))
+	return -signatureMaxAge <= d && d <= signatureMaxAge
+}
+
+// Decrypt decrypts an encrypted event. Events are encrypted by AES-256-CBC
+// with the sha256 of the encrypt key, the IV is prepended to the cipher
+// text and then they are encoded in base64.
+func Decrypt(encrypt, encryptKey string) ([]byte, error) {
+	buf, err := base64.StdEncoding.DecodeString(encrypt)
+	if err != nil {
+		return nil, err
+	}
+	if len(buf) < 2*aes.BlockSize || len(buf)%aes.BlockSize != 0 {
+		return nil, fmt.Errorf("feishu event cipher text has invalid length %d", len(buf))
+	}
+	key := sha256.Sum256([]byte(encryptKey))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	iv, plain := buf[:aes.BlockSize], make([]byte, len(buf)-aes.BlockSize)
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plain, buf[aes.BlockSize:])
+
+	// Remove PKCS#7 padding.
+	n := int(plain[len(plain)-1])
+	if n == 0 || n > aes.BlockSize || !bytes.Equal(plain[len(plain)-n:], bytes.Repeat([]byte{byte(n)}, n)) {
+		return nil, errors.New("feishu event has invalid padding, check the encrypt key")
+	}
+	return plain[:len(plain)-n], nil
+}
@@ -0,0 +1,185 @@
+// Copyright 2021 ghstats Project Authors. Licensed under MIT.
+
+package feishu
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// encrypt encrypts plain as Feishu does, with a zero IV.
+func encrypt(t *testing.T, plain, encryptKey string) string {
+	key := sha256.Sum256([]byte(encryptKey))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := aes.BlockSize - len(plain)%aes.BlockSize
+	padded := append([]byte(plain), bytes.Repeat([]byte{byte(n)}, n)...)
+	buf := make([]byte, aes.BlockSize+len(padded))
+	cipher.NewCBCEncrypter(block, buf[:aes.BlockSize]).CryptBlocks(buf[aes.BlockSize:], padded)
+	return base64.StdEncoding.EncodeToString(buf)
+}
+
+// signedHeader returns headers signed at ts.
+func signedHeader(encryptKey string, body []byte, ts time.Time) http.Header {
+	header := http.Header{}
+	header.Set("X-Lark-Request-Timestamp", strconv.FormatInt(ts.Unix(), 10))
+	header.Set("X-Lark-Request-Nonce", "nonce")
+	h := sha256.New()
+	h.Write([]byte(header.Get("X-Lark-Request-Timestamp") + "nonce" + encryptKey))
+	h.Write(body)
+	header.Set("X-Lark-Signature", hex.EncodeToString(h.Sum(nil)))
+	return header
+}
+
+func TestDecrypt(t *testing.T) {
+	cases := []struct {
+		encrypt string
+		key     string
+		plain   string
+		err     bool
+	}{
+		// The example in Feishu documents.
+		{encrypt: "P37w+VZImNgPEO1RBhJ6RtKl7n6zymIbEG1pReEzghk=", key: "test key", plain: "hello world"},
+		{encrypt: encrypt(t, `{"type":"url_verification"}`, "key"), key: "key", plain: `{"type":"url_verification"}`},
+		{encrypt: encrypt(t, "0123456789abcdef", "key"), key: "key", plain: "0123456789abcdef"},
+		{encrypt: encrypt(t, "", "key"), key: "key", plain: ""},
+		{encrypt: encrypt(t, "hello world", "key"), key: "wrong key", err: true},
+		{encrypt: "not base64!", key: "key", err: true},
+		{encrypt: base64.StdEncoding.EncodeToString(make([]byte, aes.BlockSize)), key: "key", err: true},
+		{encrypt: base64.StdEncoding.EncodeToString(make([]byte, 2*aes.BlockSize+1)), key: "key", err: true},
+	}
+	for i, c := range cases {
+		plain, err := Decrypt(c.encrypt, c.key)
+		if c.err {
+			if err == nil {
+				t.Errorf("#%d: expect an error, got %q", i, plain)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("#%d: %v", i, err)
+			continue
+		}
+		if string(plain) != c.plain {
+			t.Errorf("#%d: expect %q, got %q", i, c.plain, plain)
+		}
+	}
+}
+
+func TestValidSignature(t *testing.T) {
+	body := []byte(`{"encrypt":"xxx"}`)
+	now := time.Now()
+	cases := []struct {
+		header http.Header
+		key    string
+		body   []byte
+		valid  bool
+	}{
+		{header: signedHeader("key", body, now), key: "key", body: body, valid: true},
+		{header: signedHeader("key", body, now), key: "wrong key", body: body},
+		{header: signedHeader("key", body, now), key: "key", body: []byte(`{"encrypt":"yyy"}`)},
+		{header: signedHeader("key", []byte(`{"encrypt":"yyy"}`), now), key: "key", body: body},
+		{header: http.Header{}, key: "key", body: body},
+	}
+	for i, c := range cases {
+		if valid := validSignature(c.header, c.key, c.body); valid != c.valid {
+			t.Errorf("#%d: expect %v, got %v", i, c.valid, valid)
+		}
+	}
+
+	// The timestamp is signed.
+	header := signedHeader("key", body, now)
+	header.Set("X-Lark-Request-Timestamp", strconv.FormatInt(now.Unix()+1, 10))
+	if validSignature(header, "key", body) {
+		t.Error("expect the signature mismatches if the timestamp changes")
+	}
+}
+
+func TestFreshTimestamp(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	cases := []struct {
+		timestamp string
+		fresh     bool
+	}{
+		{timestamp: "1600000000", fresh: true},
+		{timestamp: "1599999700", fresh: true},
+		{timestamp: "1599999699"},
+		{timestamp: "1600000300", fresh: true},
+		{timestamp: "1600000301"},
+		{timestamp: ""},
+		{timestamp: "now"},
+	}
+	for _, c := range cases {
+		if fresh := freshTimestamp(c.timestamp, now); fresh != c.fresh {
+			t.Errorf("%q: expect %v, got %v", c.timestamp, c.fresh, fresh)
+		}
+	}
+}
+
+func TestEventDecoder(t *testing.T) {
+	const key = "key"
+	message := `{"schema":"2.0","header":{"event_type":"im.message.receive_v1","token":"token"}}`
+	verification := `{"type":"url_verification","token":"token","challenge":"c"}`
+	encrypted := func(plain string) []byte {
+		return []byte(`{"encrypt":"` + encrypt(t, plain, key) + `"}`)
+	}
+	now := time.Now()
+	cases := []struct {
+		decoder EventDecoder
+		header  http.Header
+		body    []byte
+		err     error
+	}{
+		{decoder: EventDecoder{VerificationToken: "token"}, body: []byte(message)},
+		{decoder: EventDecoder{VerificationToken: "wrong"}, body: []byte(message), err: ErrInvalidToken},
+		// Plain events are rejected if the encrypt key is set.
+		{decoder: EventDecoder{EncryptKey: key}, body: []byte(message), err: ErrUnencrypted},
+		{
+			decoder: EventDecoder{EncryptKey: key},
+			header:  signedHeader(key, []byte(message), now),
+			body:    []byte(message),
+			err:     ErrUnencrypted,
+		},
+		{
+			decoder: EventDecoder{EncryptKey: key},
+			header:  signedHeader(key, encrypted(message), now),
+			body:    encrypted(message),
+		},
+		// Events must be signed, except url_verification.
+		{decoder: EventDecoder{EncryptKey: key}, header: http.Header{}, body: encrypted(message), err: ErrInvalidSignature},
+		{decoder: EventDecoder{EncryptKey: key}, header: http.Header{}, body: encrypted(verification)},
+		// Stale events are rejected.
+		{
+			decoder: EventDecoder{EncryptKey: key},
+			header:  signedHeader(key, encrypted(message), now.Add(-time.Hour)),
+			body:    encrypted(message),
+			err:     ErrInvalidSignature,
+		},
+		{
+			decoder: EventDecoder{VerificationToken: "wrong", EncryptKey: key},
+			header:  signedHeader(key, encrypted(message), now),
+			body:    encrypted(message),
+			err:     ErrInvalidToken,
+		},
+	}
+	for i, c := range cases {
+		e, err := c.decoder.Decode(c.header, c.body)
+		if err != c.err {
+			t.Errorf("#%d: expect error %v, got %v", i, c.err, err)
+			continue
+		}
+		if err == nil && len(e.EventType()) == 0 {
+			t.Errorf("#%d: expect an event type", i)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+// Copyright 2021 ghstats Project Authors. Licensed under MIT.
+
+package feishu
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// MaxCardSize is the max size in bytes of the JSON encoded lark_md of a
+// card. Feishu rejects requests larger than 20KB, the rest is left for
+// the title and the card.
+//
+// Source: https://open.feishu.cn/document/ukTMukTMukTM/ucTM5YjL3ETO24yNxkjN#f62e72d5
+const MaxCardSize = 18 * 1024
+
+// MaxCardElements is the max number of elements of a card, Feishu rejects
+// cards with more elements even if they are small.
+const MaxCardElements = 50
+
+// Section is a part of a message in lark_md. Sections are split into
+// cards on blocks, e.g. a user's or a PR's lines, and the header is
+// repeated on every card that the section spans.
+type Section struct {
+	Header string
+	Blocks []string
+}
+
+// SendSections sends sections in one card, or in numbered cards if they
+// do not fit in one card.
+func (bot WebhookBot) SendSections(
+	ctx context.Context, title string, sections []Section, titleColor TitleColor,
+) error {
+	cards := SplitCards(sections, MaxCardSize)
+	for i, card := range cards {
+		t := title
+		if len(cards) > 1 {
+			t = fmt.Sprintf("%s (%d/%d)", title, i+1, len(cards))
+		}
+		if err := bot.SendMarkdownMessage(ctx, t, card, titleColor); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SplitCards packs sections into lark_md of cards whose sizes are at most
+// limit, see pack.
+func SplitCards(sections []Section, limit int) []string {
+	headers := make([]budget, len(sections))
+	blocks := make([][]budget, len(sections))
+	for i, s := range sections {
+		headers[i] = budget{size: jsonSize(s.Header)}
+		for _, b := range s.Blocks {
+			blocks[i] = append(blocks[i], budget{size: jsonSize(b)})
+		}
+	}
+	cards := make([]string, 0, 1)
+	for _, spans := range pack(headers, blocks, budget{size: limit}) {
+		card := strings.Builder{}
+		for _, sp := range spans {
+			s := sections[sp.section]
+			card.WriteString(s.Header)
+			for _, b := range s.Blocks[sp.from:sp.to] {
+				card.WriteString(b)
+			}
+		}
+		cards = append(cards, card.String())
+	}
+	return cards
+}
+
+// CardSection is a part of a card in elements, it is split like Section.
+type CardSection struct {
+	Header []Element
+	Blocks [][]Element
+}
+
+// SendCardSections sends sections in one card, or in numbered cards if
+// they do not fit in one card.
+func (bot WebhookBot) SendCardSections(
+	ctx context.Context, title string, sections []CardSection, titleColor TitleColor,
+) error {
+	return sendCards(title, sections, titleColor, func(card *Card) error {
+		return bot.SendCard(ctx, card)
+	})
+}
+
+// sendCards sends sections in numbered cards by send, see
+// SendCardSections.
+func sendCards(
+	title string, sections []CardSection, titleColor TitleColor, send func(*Card) error,
+) error {
+	cards := SplitCardSections(sections, MaxCardSize, MaxCardElements)
+	for i, elements := range cards {
+		t := title
+		if len(cards) > 1 {
+			t = fmt.Sprintf("%s (%d/%d)", title, i+1, len(cards))
+		}
+		if err := send(NewCard(t, titleColor).Add(elements...)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SplitCardSections packs sections into elements of cards whose sizes are
+// at most limit and that have at most maxElements elements, see pack.
+func SplitCardSections(sections []CardSection, limit, maxElements int) [][]Element {
+	headers := make([]budget, len(sections))
+	blocks := make([][]budget, len(sections))
+	for i, s := range sections {
+		headers[i] = budget{size: elementsSize(s.Header), elements: len(s.Header)}
+		for _, b := range s.Blocks {
+			blocks[i] = append(blocks[i], budget{size: elementsSize(b), elements: len(b)})
+		}
+	}
+	cards := make([][]Element, 0, 1)
+	for _, spans := range pack(headers, blocks, budget{size: limit, elements: maxElements}) {
+		card := make([]Element, 0)
+		for _, sp := range spans {
+			s := sections[sp.section]
+			card = append(card, s.Header...)
+			for _, b := range s.Blocks[sp.from:sp.to] {
+				card = append(card, b...)
+			}
+		}
+		cards = append(cards, card)
+	}
+	return cards
+}
+
+// span is blocks [from, to) and the header of a section in a card.
+type span struct {
+	section  int
+	from, to int
+}
+
+// budget is the size in bytes and the number of elements of a card, or of
+// a part of it.
+type budget struct {
+	size     int
+	elements int
+}
+
+func (b budget) add(o budget) budget {
+	return budget{size: b.size + o.size, elements: b.elements + o.elements}
+}
+
+func (b budget) within(limit budget) bool {
+	return b.size <= limit.size && b.elements <= limit.elements
+}
+
+// pack packs sections into cards that are within limit, given budgets of
+// headers and blocks of sections. A section starts a new card if it does
+// not fit in the current one, and only sections larger than a card are
+// split, the header is repeated in every card that the section spans.
+// Blocks are never split, a block larger than a card is in a card of its
+// own.
+func pack(headers []budget, blocks [][]budget, limit budget) [][]span {
+	cards := make([][]span, 0, 1)
+	card := make([]span, 0)
+	used := budget{}
+	flush := func() {
+		if len(card) != 0 {
+			cards = append(cards, card)
+			card = make([]span, 0)
+			used = budget{}
+		}
+	}
+	for i, header := range headers {
+		total := header
+		for _, n := range blocks[i] {
+			total = total.add(n)
+		}
+		if !used.add(total).within(limit) {
+			flush()
+		}
+		sp := span{section: i}
+		used = used.add(header)
+		for j, n := range blocks[i] {
+			if !used.add(n).within(limit) && j > sp.from {
+				sp.to = j
+				card = append(card, sp)
+				flush()
+				sp = span{section: i, from: j}
+				used = header
+			}
+			used = used.add(n)
+		}
+		sp.to = len(blocks[i])
+		card = append(card, sp)
+	}
+	flush()
+	return cards
+}
+
+// jsonSize returns the size of s in a JSON string, e.g. "<" takes 6 bytes
+// as "\u003c".
+func jsonSize(s string) int {
+	b, _ := json.Marshal(s)
+	return len(b) - 2
+}
+
+// elementsSize returns the size of elements in a JSON array.
+func elementsSize(elements []Element) int {
+	n := 0
+	for _, e := range elements {
+		b, _ := json.Marshal(e)
+		n += len(b) + 1
+	}
+	return n
+}
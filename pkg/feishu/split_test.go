@@ -0,0 +1,131 @@
+// Copyright 2021 ghstats Project Authors. Licensed under MIT.
+
+package feishu
+
+import (
+	"reflect"
+	"testing"
+)
+
+// sizes returns budgets of sizes without elements.
+func sizes(ns ...int) []budget {
+	bs := make([]budget, 0, len(ns))
+	for _, n := range ns {
+		bs = append(bs, budget{size: n})
+	}
+	return bs
+}
+
+func TestPack(t *testing.T) {
+	cases := []struct {
+		name    string
+		headers []budget
+		blocks  [][]budget
+		limit   budget
+		cards   [][]span
+	}{
+		{
+			name:    "fit in one card",
+			headers: sizes(1, 1),
+			blocks:  [][]budget{sizes(2, 2), sizes(3)},
+			limit:   budget{size: 10},
+			cards:   [][]span{{{section: 0, from: 0, to: 2}, {section: 1, from: 0, to: 1}}},
+		},
+		{
+			name:    "a section starts a new card",
+			headers: sizes(1, 1),
+			blocks:  [][]budget{sizes(3, 3), sizes(3)},
+			limit:   budget{size: 10},
+			cards:   [][]span{{{section: 0, from: 0, to: 2}}, {{section: 1, from: 0, to: 1}}},
+		},
+		{
+			name:    "a section spans several cards",
+			headers: sizes(2),
+			blocks:  [][]budget{sizes(4, 4, 4, 4, 4)},
+			limit:   budget{size: 10},
+			cards: [][]span{
+				{{section: 0, from: 0, to: 2}},
+				{{section: 0, from: 2, to: 4}},
+				{{section: 0, from: 4, to: 5}},
+			},
+		},
+		{
+			name:    "a block larger than the limit",
+			headers: sizes(1, 1),
+			blocks:  [][]budget{sizes(2, 20, 2), sizes(2)},
+			limit:   budget{size: 10},
+			cards: [][]span{
+				{{section: 0, from: 0, to: 1}},
+				{{section: 0, from: 1, to: 2}},
+				{{section: 0, from: 2, to: 3}, {section: 1, from: 0, to: 1}},
+			},
+		},
+		{
+			name:    "a section without blocks",
+			headers: sizes(1, 1),
+			blocks:  [][]budget{nil, sizes(2)},
+			limit:   budget{size: 10},
+			cards:   [][]span{{{section: 0, from: 0, to: 0}, {section: 1, from: 0, to: 1}}},
+		},
+		{
+			name:    "elements are limited",
+			headers: []budget{{size: 1, elements: 1}},
+			blocks:  [][]budget{{{size: 1, elements: 2}, {size: 1, elements: 2}, {size: 1, elements: 2}}},
+			limit:   budget{size: 100, elements: 5},
+			cards: [][]span{
+				{{section: 0, from: 0, to: 2}},
+				{{section: 0, from: 2, to: 3}},
+			},
+		},
+		{
+			name:  "no sections",
+			limit: budget{size: 10},
+			cards: [][]span{},
+		},
+	}
+	for _, c := range cases {
+		if cards := pack(c.headers, c.blocks, c.limit); !reflect.DeepEqual(cards, c.cards) {
+			t.Errorf("%s: expect %v, got %v", c.name, c.cards, cards)
+		}
+	}
+}
+
+func TestSplitCardsRepeatHeaders(t *testing.T) {
+	sections := []Section{
+		{Header: "A\n", Blocks: []string{"a1\n", "a2\n", "a3\n"}},
+		{Header: "B\n", Blocks: []string{"b1\n"}},
+	}
+	// Newlines take 2 bytes in JSON, a header takes 3 bytes and a block
+	// takes 4 bytes.
+	cards := SplitCards(sections, 11)
+	expect := []string{"A\na1\na2\n", "A\na3\n", "B\nb1\n"}
+	if !reflect.DeepEqual(cards, expect) {
+		t.Errorf("expect %q, got %q", expect, cards)
+	}
+}
+
+func TestSplitCardSections(t *testing.T) {
+	header := []Element{Markdown("header")}
+	block := []Element{Markdown("a"), Markdown("b")}
+	sections := []CardSection{{Header: header, Blocks: [][]Element{block, block, block}}}
+
+	// The header is repeated in every card that the section spans.
+	cards := SplitCardSections(sections, MaxCardSize, 5)
+	if len(cards) != 2 {
+		t.Fatalf("expect 2 cards, got %d", len(cards))
+	}
+	for i, card := range cards {
+		if len(card) > 5 {
+			t.Errorf("card %d: expect at most 5 elements, got %d", i, len(card))
+		}
+		if !reflect.DeepEqual(card[0], header[0]) {
+			t.Errorf("card %d: expect the header first, got %v", i, card[0])
+		}
+	}
+
+	size := elementsSize(header) + 2*elementsSize(block)
+	cards = SplitCardSections(sections, size, MaxCardElements)
+	if len(cards) != 2 || len(cards[0]) != 5 || len(cards[1]) != 3 {
+		t.Errorf("expect cards of 5 and 3 elements, got %v", cards)
+	}
+}
@@ -0,0 +1,195 @@
+// Copyright 2021 ghstats Project Authors. Licensed under MIT.
+
+package gh
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// CacheTransport is a http.RoundTripper that caches GitHub GET responses.
+//
+// A response is keyed by its URL, which contains the endpoint, owner, repo,
+// number and query parameters, and is fetched at most once during the
+// lifetime of the transport. Concurrent requests of the same resource are
+// deduplicated.
+//
+// If Dir is not empty, responses are also persisted to Dir and revalidated
+// with ETag and If-None-Match in later runs. GitHub does not count
+// 304 Not Modified responses against the rate limit.
+type CacheTransport struct {
+	Base http.RoundTripper
+	Dir  string
+
+	mu      sync.Mutex
+	entries map[string]*cacheEntry
+}
+
+type cacheEntry struct {
+	done chan struct{}
+	resp *cachedResponse
+	err  error
+}
+
+type cachedResponse struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header"`
+	Body       []byte      `json:"body"`
+}
+
+// NewCacheTransport returns a CacheTransport on top of base.
+func NewCacheTransport(base http.RoundTripper, dir string) *CacheTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &CacheTransport{Base: base, Dir: dir, entries: make(map[string]*cacheEntry)}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *CacheTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet {
+		return t.Base.RoundTrip(req)
+	}
+	// go-github selects API previews via Accept, they change responses.
+	key := req.URL.String() + " " + req.Header.Get("Accept")
+
+	t.mu.Lock()
+	if e, ok := t.entries[key]; ok {
+		t.mu.Unlock()
+		<-e.done
+		if e.err != nil {
+			return nil, e.err
+		}
+		log.Debugf("cache hit %s", req.URL)
+		return e.resp.toResponse(req), nil
+	}
+	e := &cacheEntry{done: make(chan struct{})}
+	t.entries[key] = e
+	t.mu.Unlock()
+
+	e.resp, e.err = t.fetch(req, key)
+	if e.err != nil || e.resp.StatusCode != http.StatusOK {
+		// Do not keep errors, e.g. rate limit responses must be retried.
+		t.mu.Lock()
+		delete(t.entries, key)
+		t.mu.Unlock()
+	}
+	close(e.done)
+	if e.err != nil {
+		return nil, e.err
+	}
+	return e.resp.toResponse(req), nil
+}
+
+func (t *CacheTransport) fetch(req *http.Request, key string) (*cachedResponse, error) {
+	stale := t.load(key)
+	if stale != nil {
+		if etag := stale.Header.Get("ETag"); etag != "" {
+			req = req.Clone(req.Context())
+			req.Header.Set("If-None-Match", etag)
+		}
+	}
+	resp, err := t.Base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotModified && stale != nil {
+		log.Debugf("cache revalidated %s", req.URL)
+		// Keep rate limit headers up to date.
+		for name, values := range resp.Header {
+			if strings.HasPrefix(name, "X-Ratelimit-") {
+				stale.Header[name] = values
+			}
+		}
+		return stale, nil
+	}
+	cr := &cachedResponse{StatusCode: resp.StatusCode, Header: resp.Header, Body: body}
+	if cr.StatusCode == http.StatusOK {
+		t.save(key, cr)
+	}
+	return cr, nil
+}
+
+func (t *CacheTransport) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(t.Dir, hex.EncodeToString(sum[:]))
+}
+
+func (t *CacheTransport) load(key string) *cachedResponse {
+	if t.Dir == "" {
+		return nil
+	}
+	b, err := ioutil.ReadFile(t.path(key))
+	if err != nil {
+		return nil
+	}
+	cr := &cachedResponse{}
+	if err := json.Unmarshal(b, cr); err != nil {
+		log.Warnf("drop corrupted cache of %s: %s", key, err)
+		return nil
+	}
+	return cr
+}
+
+func (t *CacheTransport) save(key string, cr *cachedResponse) {
+	if t.Dir == "" || cr.Header.Get("ETag") == "" {
+		return
+	}
+	b, err := json.Marshal(cr)
+	if err != nil {
+		log.Warnf("marshal cache of %s: %s", key, err)
+		return
+	}
+	if err := os.MkdirAll(t.Dir, 0o755); err != nil {
+		log.Warnf("create cache dir: %s", err)
+		return
+	}
+	// Write to a temporary file first, so that readers never see
+	// a partially written cache.
+	f, err := ioutil.TempFile(t.Dir, "tmp-")
+	if err != nil {
+		log.Warnf("save cache of %s: %s", key, err)
+		return
+	}
+	_, err = f.Write(b)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), t.path(key))
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		log.Warnf("save cache of %s: %s", key, err)
+	}
+}
+
+func (cr *cachedResponse) toResponse(req *http.Request) *http.Response {
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", cr.StatusCode, http.StatusText(cr.StatusCode)),
+		StatusCode:    cr.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        cr.Header.Clone(),
+		Body:          ioutil.NopCloser(bytes.NewReader(cr.Body)),
+		ContentLength: int64(len(cr.Body)),
+		Request:       req,
+	}
+}
@@ -0,0 +1,146 @@
+// Copyright 2021 ghstats Project Authors. Licensed under MIT.
+
+package gh
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func cacheGet(t *testing.T, tr http.RoundTripper, url string) (*http.Response, string) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := tr.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, string(body)
+}
+
+func TestCacheTransportConcurrent(t *testing.T) {
+	var requests int32
+	arrived := make(chan struct{})
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			close(arrived)
+		}
+		<-release
+		w.Write([]byte("pulls"))
+	}))
+	defer srv.Close()
+
+	tr := NewCacheTransport(nil, "")
+	var wg sync.WaitGroup
+	bodies := make([]string, 8)
+	for i := range bodies {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			req, _ := http.NewRequest(http.MethodGet, srv.URL+"/repos/o/r/pulls", nil)
+			resp, err := tr.RoundTrip(req)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer resp.Body.Close()
+			b, _ := ioutil.ReadAll(resp.Body)
+			bodies[i] = string(b)
+		}(i)
+	}
+	// Requests that come later than the first one wait for it, or hit
+	// the cache once it is done.
+	<-arrived
+	close(release)
+	wg.Wait()
+
+	if n := atomic.LoadInt32(&requests); n != 1 {
+		t.Errorf("expect 1 request, got %d", n)
+	}
+	for i, b := range bodies {
+		if b != "pulls" {
+			t.Errorf("#%d: expect body %q, got %q", i, "pulls", b)
+		}
+	}
+}
+
+func TestCacheTransportNotOK(t *testing.T) {
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte("rate limited"))
+			return
+		}
+		w.Write([]byte("pulls"))
+	}))
+	defer srv.Close()
+
+	tr := NewCacheTransport(nil, t.TempDir())
+	url := srv.URL + "/repos/o/r/pulls"
+	if resp, body := cacheGet(t, tr, url); resp.StatusCode != http.StatusForbidden || body != "rate limited" {
+		t.Fatalf("expect 403 rate limited, got %d %q", resp.StatusCode, body)
+	}
+	// The 403 is retried, the 200 is kept.
+	for i := 0; i < 2; i++ {
+		if resp, body := cacheGet(t, tr, url); resp.StatusCode != http.StatusOK || body != "pulls" {
+			t.Fatalf("expect 200 pulls, got %d %q", resp.StatusCode, body)
+		}
+	}
+	if n := atomic.LoadInt32(&requests); n != 2 {
+		t.Errorf("expect 2 requests, got %d", n)
+	}
+}
+
+func TestCacheTransportRevalidate(t *testing.T) {
+	var requests, notModified int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&requests, 1)
+		w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(5000-int(n)))
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			atomic.AddInt32(&notModified, 1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte("pulls"))
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	url := srv.URL + "/repos/o/r/pulls"
+	resp, body := cacheGet(t, NewCacheTransport(nil, dir), url)
+	if resp.StatusCode != http.StatusOK || body != "pulls" {
+		t.Fatalf("expect 200 pulls, got %d %q", resp.StatusCode, body)
+	}
+	if n := atomic.LoadInt32(&notModified); n != 0 {
+		t.Fatalf("expect no If-None-Match without a persisted entry, got %d", n)
+	}
+
+	// A later run revalidates the persisted entry.
+	resp, body = cacheGet(t, NewCacheTransport(nil, dir), url)
+	if n := atomic.LoadInt32(&notModified); n != 1 {
+		t.Fatalf("expect a request with If-None-Match, got %d", n)
+	}
+	if resp.StatusCode != http.StatusOK || body != "pulls" {
+		t.Errorf("expect the persisted 200 pulls, got %d %q", resp.StatusCode, body)
+	}
+	if etag := resp.Header.Get("ETag"); etag != `"v1"` {
+		t.Errorf("expect ETag %q, got %q", `"v1"`, etag)
+	}
+	if remaining := resp.Header.Get("X-RateLimit-Remaining"); remaining != "4998" {
+		t.Errorf("expect the refreshed rate limit 4998, got %q", remaining)
+	}
+}
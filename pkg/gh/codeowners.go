@@ -0,0 +1,97 @@
+// Copyright 2021 ghstats Project Authors. Licensed under MIT.
+
+package gh
+
+import (
+	"regexp"
+	"strings"
+)
+
+// CodeOwnersPaths are where GitHub looks for CODEOWNERS, the first one
+// that exists is used.
+//
+// Source: https://docs.github.com/en/repositories/managing-your-repositorys-settings-and-features/customizing-your-repository/about-code-owners
+var CodeOwnersPaths = []string{".github/CODEOWNERS", "CODEOWNERS", "docs/CODEOWNERS"}
+
+// CodeOwners are rules of a CODEOWNERS file.
+type CodeOwners []codeOwnersRule
+
+type codeOwnersRule struct {
+	pattern *regexp.Regexp
+	// Owners are @user, @org/team or email.
+	owners []string
+}
+
+// ParseCodeOwners parses a CODEOWNERS file, invalid lines are skipped.
+func ParseCodeOwners(content string) CodeOwners {
+	rules := make(CodeOwners, 0)
+	for _, line := range strings.Split(content, "\n") {
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		re, err := regexp.Compile(codeOwnersPattern(fields[0]))
+		if err != nil {
+			continue
+		}
+		rules = append(rules, codeOwnersRule{pattern: re, owners: fields[1:]})
+	}
+	return rules
+}
+
+// Owners returns owners of the file, the last matching rule wins.
+func (c CodeOwners) Owners(path string) []string {
+	path = strings.TrimPrefix(path, "/")
+	for i := len(c) - 1; i >= 0; i-- {
+		if c[i].pattern.MatchString(path) {
+			return c[i].owners
+		}
+	}
+	return nil
+}
+
+// codeOwnersPattern converts a gitignore style pattern to a regexp. A
+// pattern that contains a slash except a trailing one is relative to the
+// root, otherwise it matches at any level. A pattern matches a file or
+// everything in a directory, except that dir/* matches files in dir only.
+func codeOwnersPattern(pattern string) string {
+	dir := strings.HasSuffix(pattern, "/")
+	pattern = strings.TrimSuffix(pattern, "/")
+	anchored := strings.Contains(pattern, "/")
+	pattern = strings.TrimPrefix(pattern, "/")
+
+	b := strings.Builder{}
+	b.WriteString("^")
+	if !anchored {
+		b.WriteString("(.*/)?")
+	}
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; {
+		case strings.HasPrefix(pattern[i:], "**/"):
+			b.WriteString("(.*/)?")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	switch {
+	case dir:
+		b.WriteString("/.*$")
+	case strings.HasSuffix(pattern, "/*"):
+		// Files in subdirectories are not matched, e.g. docs/*.
+		b.WriteString("$")
+	default:
+		b.WriteString("(/.*)?$")
+	}
+	return b.String()
+}
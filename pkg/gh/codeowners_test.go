@@ -0,0 +1,106 @@
+// Copyright 2021 ghstats Project Authors. Licensed under MIT.
+
+package gh
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func TestCodeOwnersPattern(t *testing.T) {
+	cases := []struct {
+		pattern  string
+		match    []string
+		mismatch []string
+	}{
+		{
+			pattern: "*",
+			match:   []string{"README.md", "docs/README.md", "a/b/c.go"},
+		},
+		{
+			pattern:  "*.js",
+			match:    []string{"app.js", "web/src/app.js"},
+			mismatch: []string{"app.jsx", "app.ts"},
+		},
+		{
+			pattern:  "/docs/",
+			match:    []string{"docs/README.md", "docs/build/app.md"},
+			mismatch: []string{"docs", "src/docs/README.md", "docsite/README.md"},
+		},
+		{
+			pattern:  "apps/",
+			match:    []string{"apps/main.go", "web/apps/main.go", "web/apps/a/b.go"},
+			mismatch: []string{"apps", "myapps/main.go"},
+		},
+		{
+			pattern:  "docs/*",
+			match:    []string{"docs/getting-started.md"},
+			mismatch: []string{"docs/build-app/troubleshooting.md", "src/docs/README.md"},
+		},
+		{
+			pattern:  "**/logs",
+			match:    []string{"logs", "logs/a.log", "build/logs/a.log", "deeply/nested/logs/a/b.log"},
+			mismatch: []string{"build/logsite/a.log", "mylogs/a.log"},
+		},
+		{
+			pattern:  "/build/logs/",
+			match:    []string{"build/logs/a.log", "build/logs/a/b.log"},
+			mismatch: []string{"src/build/logs/a.log", "build/logs"},
+		},
+		{
+			pattern:  "pkg/store",
+			match:    []string{"pkg/store", "pkg/store/store.go"},
+			mismatch: []string{"cmd/pkg/store/store.go", "pkg/stores/a.go"},
+		},
+		{
+			pattern:  "file?.go",
+			match:    []string{"file1.go", "a/fileA.go"},
+			mismatch: []string{"file.go", "file/.go"},
+		},
+	}
+	for _, c := range cases {
+		re, err := regexp.Compile(codeOwnersPattern(c.pattern))
+		if err != nil {
+			t.Errorf("%s: %v", c.pattern, err)
+			continue
+		}
+		for _, path := range c.match {
+			if !re.MatchString(path) {
+				t.Errorf("%s: expect %s matches, regexp %s", c.pattern, path, re)
+			}
+		}
+		for _, path := range c.mismatch {
+			if re.MatchString(path) {
+				t.Errorf("%s: expect %s mismatches, regexp %s", c.pattern, path, re)
+			}
+		}
+	}
+}
+
+func TestCodeOwnersOwners(t *testing.T) {
+	c := ParseCodeOwners(`
+# Default owners.
+*       @org/core
+/docs/  @alice alice@example.com # Docs owners.
+*.md    @bob
+/docs/internal/
+`)
+	cases := []struct {
+		path   string
+		owners []string
+	}{
+		{path: "main.go", owners: []string{"@org/core"}},
+		{path: "docs/index.html", owners: []string{"@alice", "alice@example.com"}},
+		// The last matching rule wins.
+		{path: "docs/README.md", owners: []string{"@bob"}},
+		{path: "/docs/index.html", owners: []string{"@alice", "alice@example.com"}},
+		// A rule without owners clears owners.
+		{path: "docs/internal/README.md", owners: []string{}},
+	}
+	for _, o := range cases {
+		if owners := c.Owners(o.path); !reflect.DeepEqual(owners, o.owners) {
+			t.Errorf("%s: expect %q, got %q", o.path, o.owners, owners)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+// Copyright 2021 ghstats Project Authors. Licensed under MIT.
+
+package gh
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/go-github/v35/github"
+)
+
+// Fetcher fetches the GitHub resources that reports are computed from.
+// Method names and signatures mirror the wrappers in this package.
+type Fetcher interface {
+	SearchIssues(ctx context.Context, query string) ([]*github.IssuesSearchResult, error)
+	IssuesListComments(
+		ctx context.Context, owner, repo string, number int, since *time.Time,
+	) ([]*github.IssueComment, error)
+	IssuesListIssueEvents(
+		ctx context.Context, owner, repo string, number int,
+	) ([]*github.IssueEvent, error)
+	PullRequestsListReviews(
+		ctx context.Context, owner, repo string, number int,
+	) ([]*github.PullRequestReview, error)
+	PullRequestsListReviewComments(
+		ctx context.Context, owner, repo string, number int, reviewID int64,
+	) ([]*github.PullRequestComment, error)
+	PullRequestsList(
+		ctx context.Context, owner, repo string, maxPages int,
+	) ([]*github.PullRequest, error)
+	PullRequestsListFiles(
+		ctx context.Context, owner, repo string, number int,
+	) ([]*github.CommitFile, error)
+	PullRequestsGet(
+		ctx context.Context, owner, repo string, number int,
+	) (*github.PullRequest, error)
+	RepositoriesGetFile(
+		ctx context.Context, owner, repo, path, ref string,
+	) (string, error)
+	TeamsListMembers(
+		ctx context.Context, org, slug string,
+	) ([]*github.User, error)
+}
+
+// NewFetcher returns a Fetcher backed by the GitHub REST API.
+func NewFetcher(client *github.Client) Fetcher {
+	return restFetcher{client: client}
+}
+
+type restFetcher struct {
+	client *github.Client
+}
+
+func (f restFetcher) SearchIssues(
+	ctx context.Context, query string,
+) ([]*github.IssuesSearchResult, error) {
+	return SearchIssues(ctx, f.client, query)
+}
+
+func (f restFetcher) IssuesListComments(
+	ctx context.Context, owner, repo string, number int, since *time.Time,
+) ([]*github.IssueComment, error) {
+	return IssuesListComments(ctx, f.client, owner, repo, number, since)
+}
+
+func (f restFetcher) IssuesListIssueEvents(
+	ctx context.Context, owner, repo string, number int,
+) ([]*github.IssueEvent, error) {
+	return IssuesListIssueEvents(ctx, f.client, owner, repo, number)
+}
+
+func (f restFetcher) PullRequestsListReviews(
+	ctx context.Context, owner, repo string, number int,
+) ([]*github.PullRequestReview, error) {
+	return PullRequestsListReviews(ctx, f.client, owner, repo, number)
+}
+
+func (f restFetcher) PullRequestsListReviewComments(
+	ctx context.Context, owner, repo string, number int, reviewID int64,
+) ([]*github.PullRequestComment, error) {
+	return PullRequestsListReviewComments(ctx, f.client, owner, repo, number, reviewID)
+}
+
+func (f restFetcher) PullRequestsList(
+	ctx context.Context, owner, repo string, maxPages int,
+) ([]*github.PullRequest, error) {
+	return PullRequestsList(ctx, f.client, owner, repo, maxPages)
+}
+
+func (f restFetcher) PullRequestsListFiles(
+	ctx context.Context, owner, repo string, number int,
+) ([]*github.CommitFile, error) {
+	return PullRequestsListFiles(ctx, f.client, owner, repo, number)
+}
+
+func (f restFetcher) PullRequestsGet(
+	ctx context.Context, owner, repo string, number int,
+) (*github.PullRequest, error) {
+	return PullRequestsGet(ctx, f.client, owner, repo, number)
+}
+
+func (f restFetcher) RepositoriesGetFile(
+	ctx context.Context, owner, repo, path, ref string,
+) (string, error) {
+	return RepositoriesGetFile(ctx, f.client, owner, repo, path, ref)
+}
+
+func (f restFetcher) TeamsListMembers(
+	ctx context.Context, org, slug string,
+) ([]*github.User, error) {
+	return TeamsListMembers(ctx, f.client, org, slug)
+}
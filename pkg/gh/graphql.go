@@ -0,0 +1,520 @@
+// Copyright 2021 ghstats Project Authors. Licensed under MIT.
+
+package gh
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v35/github"
+	log "github.com/sirupsen/logrus"
+)
+
+const graphqlURL = "https://api.github.com/graphql"
+
+// Fields of an issue or a pull request, connections are sized to keep
+// a search page well below the GraphQL node limit.
+const graphqlIssueFragment = `
+fragment issueFields on Issue {
+  number title url state createdAt updatedAt closedAt
+  author { login }
+  repository { nameWithOwner }
+  labels(first: 50) { nodes { name } }
+  comments(first: 100) {
+    pageInfo { hasNextPage }
+    nodes { databaseId body url createdAt updatedAt author { login } }
+  }
+}
+`
+
+const graphqlPullRequestFragment = `
+fragment pullRequestFields on PullRequest {
+  number title url state createdAt updatedAt closedAt mergedAt isDraft additions deletions
+  author { login }
+  repository { nameWithOwner }
+  labels(first: 50) { nodes { name } }
+  comments(first: 100) {
+    pageInfo { hasNextPage }
+    nodes { databaseId body url createdAt updatedAt author { login } }
+  }
+  reviews(first: 50) {
+    pageInfo { hasNextPage }
+    nodes {
+      databaseId state body url submittedAt author { login }
+      comments(first: 50) {
+        pageInfo { hasNextPage }
+        nodes { databaseId body path url createdAt updatedAt author { login } }
+      }
+    }
+  }
+  files(first: 100) {
+    pageInfo { hasNextPage }
+    nodes { path additions deletions changeType }
+  }
+}
+`
+
+const graphqlSearchQuery = `
+query($query: String!, $after: String) {
+  search(query: $query, type: ISSUE, first: 25, after: $after) {
+    issueCount
+    pageInfo { hasNextPage endCursor }
+    nodes {
+      __typename
+      ...issueFields
+      ...pullRequestFields
+    }
+  }
+}` + graphqlIssueFragment + graphqlPullRequestFragment
+
+const graphqlPullRequestsQuery = `
+query($owner: String!, $name: String!, $after: String) {
+  repository(owner: $owner, name: $name) {
+    pullRequests(first: 30, after: $after, orderBy: {field: CREATED_AT, direction: DESC}) {
+      pageInfo { hasNextPage endCursor }
+      nodes { ...pullRequestFields }
+    }
+  }
+}` + graphqlPullRequestFragment
+
+// NewGraphQLFetcher returns a Fetcher backed by the GitHub GraphQL v4 API.
+//
+// SearchIssues and PullRequestsList fetch a page of issues or pull requests
+// together with their reviews, review comments, comments, labels and
+// changed files in one query. Later calls for these resources are served
+// from the prefetched results, resources that are not prefetched, or have
+// more items than a query returns, are fetched by fallback.
+func NewGraphQLFetcher(httpClient *http.Client, fallback Fetcher) Fetcher {
+	return &graphqlFetcher{
+		Fetcher:    fallback,
+		httpClient: httpClient,
+		prefetched: make(map[string]*prefetched),
+	}
+}
+
+type graphqlFetcher struct {
+	// Fallback for resources that are not prefetched.
+	Fetcher
+	httpClient *http.Client
+
+	mu         sync.Mutex
+	prefetched map[string]*prefetched
+}
+
+// prefetched resources of an issue or a pull request, a nil slice means
+// the resource is not completely fetched.
+type prefetched struct {
+	comments       []*github.IssueComment
+	reviews        []*github.PullRequestReview
+	reviewComments map[int64][]*github.PullRequestComment
+	files          []*github.CommitFile
+}
+
+func prefetchKey(owner, repo string, number int) string {
+	return fmt.Sprintf("%s/%s#%d", owner, repo, number)
+}
+
+func (f *graphqlFetcher) lookup(owner, repo string, number int) *prefetched {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.prefetched[prefetchKey(owner, repo, number)]
+}
+
+func (f *graphqlFetcher) SearchIssues(
+	ctx context.Context, query string,
+) ([]*github.IssuesSearchResult, error) {
+	results := make([]*github.IssuesSearchResult, 0)
+	var after *string
+	for {
+		var data struct {
+			Search struct {
+				IssueCount int                 `json:"issueCount"`
+				PageInfo   graphqlPageInfo     `json:"pageInfo"`
+				Nodes      []*graphqlIssueOrPR `json:"nodes"`
+			} `json:"search"`
+		}
+		vars := map[string]interface{}{"query": query, "after": after}
+		if err := f.do(ctx, graphqlSearchQuery, vars, &data); err != nil {
+			return nil, err
+		}
+		issues := make([]*github.Issue, 0, len(data.Search.Nodes))
+		for _, node := range data.Search.Nodes {
+			if node.Typename != "Issue" && node.Typename != "PullRequest" {
+				continue
+			}
+			issues = append(issues, node.toIssue())
+			f.save(node)
+		}
+		total := data.Search.IssueCount
+		results = append(results, &github.IssuesSearchResult{Total: &total, Issues: issues})
+		if !data.Search.PageInfo.HasNextPage {
+			break
+		}
+		after = &data.Search.PageInfo.EndCursor
+	}
+	return results, nil
+}
+
+func (f *graphqlFetcher) PullRequestsList(
+	ctx context.Context, owner, repo string, maxPages int,
+) ([]*github.PullRequest, error) {
+	prs := make([]*github.PullRequest, 0, 30)
+	var after *string
+	for page := 0; page < maxPages; page++ {
+		var data struct {
+			Repository struct {
+				PullRequests struct {
+					PageInfo graphqlPageInfo     `json:"pageInfo"`
+					Nodes    []*graphqlIssueOrPR `json:"nodes"`
+				} `json:"pullRequests"`
+			} `json:"repository"`
+		}
+		vars := map[string]interface{}{"owner": owner, "name": repo, "after": after}
+		if err := f.do(ctx, graphqlPullRequestsQuery, vars, &data); err != nil {
+			return nil, err
+		}
+		for _, node := range data.Repository.PullRequests.Nodes {
+			node.Typename = "PullRequest"
+			prs = append(prs, node.toPullRequest())
+			f.save(node)
+		}
+		if !data.Repository.PullRequests.PageInfo.HasNextPage {
+			break
+		}
+		after = &data.Repository.PullRequests.PageInfo.EndCursor
+	}
+	return prs, nil
+}
+
+func (f *graphqlFetcher) IssuesListComments(
+	ctx context.Context, owner, repo string, number int, since *time.Time,
+) ([]*github.IssueComment, error) {
+	p := f.lookup(owner, repo, number)
+	if p == nil || p.comments == nil {
+		return f.Fetcher.IssuesListComments(ctx, owner, repo, number, since)
+	}
+	comments := make([]*github.IssueComment, 0, len(p.comments))
+	for _, comment := range p.comments {
+		// Mirror the REST API, since filters by the update time.
+		if since == nil || !comment.UpdatedAt.Before(*since) {
+			comments = append(comments, comment)
+		}
+	}
+	return comments, nil
+}
+
+func (f *graphqlFetcher) PullRequestsListReviews(
+	ctx context.Context, owner, repo string, number int,
+) ([]*github.PullRequestReview, error) {
+	p := f.lookup(owner, repo, number)
+	if p == nil || p.reviews == nil {
+		return f.Fetcher.PullRequestsListReviews(ctx, owner, repo, number)
+	}
+	return p.reviews, nil
+}
+
+func (f *graphqlFetcher) PullRequestsListReviewComments(
+	ctx context.Context, owner, repo string, number int, reviewID int64,
+) ([]*github.PullRequestComment, error) {
+	p := f.lookup(owner, repo, number)
+	if p != nil {
+		if comments, ok := p.reviewComments[reviewID]; ok {
+			return comments, nil
+		}
+	}
+	return f.Fetcher.PullRequestsListReviewComments(ctx, owner, repo, number, reviewID)
+}
+
+func (f *graphqlFetcher) PullRequestsListFiles(
+	ctx context.Context, owner, repo string, number int,
+) ([]*github.CommitFile, error) {
+	p := f.lookup(owner, repo, number)
+	if p == nil || p.files == nil {
+		return f.Fetcher.PullRequestsListFiles(ctx, owner, repo, number)
+	}
+	return p.files, nil
+}
+
+func (f *graphqlFetcher) save(node *graphqlIssueOrPR) {
+	owner, repo := node.ownerRepo()
+	p := &prefetched{reviewComments: make(map[int64][]*github.PullRequestComment)}
+	if !node.Comments.PageInfo.HasNextPage {
+		p.comments = make([]*github.IssueComment, 0, len(node.Comments.Nodes))
+		for _, c := range node.Comments.Nodes {
+			p.comments = append(p.comments, &github.IssueComment{
+				ID:        github.Int64(c.DatabaseID),
+				Body:      github.String(c.Body),
+				HTMLURL:   github.String(c.URL),
+				User:      c.Author.toUser(),
+				CreatedAt: timePtr(c.CreatedAt),
+				UpdatedAt: timePtr(c.UpdatedAt),
+			})
+		}
+	}
+	if node.Typename == "PullRequest" {
+		if !node.Reviews.PageInfo.HasNextPage {
+			p.reviews = make([]*github.PullRequestReview, 0, len(node.Reviews.Nodes))
+		}
+		for _, r := range node.Reviews.Nodes {
+			// Skip pending reviews, they are not submitted yet.
+			if p.reviews != nil && r.SubmittedAt != nil {
+				p.reviews = append(p.reviews, &github.PullRequestReview{
+					ID:          github.Int64(r.DatabaseID),
+					Body:        github.String(r.Body),
+					HTMLURL:     github.String(r.URL),
+					State:       github.String(r.State),
+					User:        r.Author.toUser(),
+					SubmittedAt: r.SubmittedAt,
+				})
+			}
+			if r.Comments.PageInfo.HasNextPage {
+				continue
+			}
+			comments := make([]*github.PullRequestComment, 0, len(r.Comments.Nodes))
+			for _, c := range r.Comments.Nodes {
+				comments = append(comments, &github.PullRequestComment{
+					ID:                  github.Int64(c.DatabaseID),
+					PullRequestReviewID: github.Int64(r.DatabaseID),
+					Body:                github.String(c.Body),
+					Path:                github.String(c.Path),
+					HTMLURL:             github.String(c.URL),
+					User:                c.Author.toUser(),
+					CreatedAt:           timePtr(c.CreatedAt),
+					UpdatedAt:           timePtr(c.UpdatedAt),
+				})
+			}
+			p.reviewComments[r.DatabaseID] = comments
+		}
+		if !node.Files.PageInfo.HasNextPage {
+			p.files = make([]*github.CommitFile, 0, len(node.Files.Nodes))
+			for _, file := range node.Files.Nodes {
+				p.files = append(p.files, &github.CommitFile{
+					Filename:  github.String(file.Path),
+					Additions: github.Int(file.Additions),
+					Deletions: github.Int(file.Deletions),
+					Changes:   github.Int(file.Additions + file.Deletions),
+					Status:    github.String(strings.ToLower(file.ChangeType)),
+				})
+			}
+		}
+	}
+	f.mu.Lock()
+	f.prefetched[prefetchKey(owner, repo, node.Number)] = p
+	f.mu.Unlock()
+}
+
+// do sends a GraphQL query and decodes its data into out,
+// supports rate limit.
+func (f *graphqlFetcher) do(
+	ctx context.Context, query string, vars map[string]interface{}, out interface{},
+) error {
+	payload, err := json.Marshal(map[string]interface{}{"query": query, "variables": vars})
+	if err != nil {
+		return err
+	}
+	for {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, graphqlURL, bytes.NewReader(payload))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := f.httpClient.Do(req)
+		if err != nil {
+			return err
+		}
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+		var result struct {
+			Data   json.RawMessage `json:"data"`
+			Errors []struct {
+				Type    string `json:"type"`
+				Message string `json:"message"`
+			} `json:"errors"`
+		}
+		if resp.StatusCode == http.StatusOK {
+			if err := json.Unmarshal(body, &result); err != nil {
+				return err
+			}
+		}
+		rateLimited := resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusTooManyRequests
+		for _, e := range result.Errors {
+			rateLimited = rateLimited || e.Type == "RATE_LIMITED"
+		}
+		if rateLimited && resp.Header.Get("X-RateLimit-Remaining") == "0" {
+			reset, _ := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64)
+			dur := time.Until(time.Unix(reset, 0)) + 100*time.Millisecond
+			log.Warnf("hit graphql rate limit, sleep %s", dur)
+			time.Sleep(dur)
+			continue
+		}
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("graphql error [%d] %s", resp.StatusCode, string(body))
+		}
+		if len(result.Errors) != 0 {
+			return fmt.Errorf("graphql error %s", result.Errors[0].Message)
+		}
+		return json.Unmarshal(result.Data, out)
+	}
+}
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
+
+type graphqlPageInfo struct {
+	HasNextPage bool   `json:"hasNextPage"`
+	EndCursor   string `json:"endCursor"`
+}
+
+type graphqlActor struct {
+	Login string `json:"login"`
+}
+
+// toUser returns the user of an actor, deleted users are "ghost".
+func (a *graphqlActor) toUser() *github.User {
+	if a == nil {
+		return &github.User{Login: github.String("ghost")}
+	}
+	return &github.User{Login: github.String(a.Login)}
+}
+
+type graphqlComment struct {
+	DatabaseID int64         `json:"databaseId"`
+	Body       string        `json:"body"`
+	Path       string        `json:"path"`
+	URL        string        `json:"url"`
+	CreatedAt  time.Time     `json:"createdAt"`
+	UpdatedAt  time.Time     `json:"updatedAt"`
+	Author     *graphqlActor `json:"author"`
+}
+
+type graphqlIssueOrPR struct {
+	Typename   string        `json:"__typename"`
+	Number     int           `json:"number"`
+	Title      string        `json:"title"`
+	URL        string        `json:"url"`
+	State      string        `json:"state"`
+	CreatedAt  time.Time     `json:"createdAt"`
+	UpdatedAt  time.Time     `json:"updatedAt"`
+	ClosedAt   *time.Time    `json:"closedAt"`
+	MergedAt   *time.Time    `json:"mergedAt"`
+	IsDraft    bool          `json:"isDraft"`
+	Additions  int           `json:"additions"`
+	Deletions  int           `json:"deletions"`
+	Author     *graphqlActor `json:"author"`
+	Repository struct {
+		NameWithOwner string `json:"nameWithOwner"`
+	} `json:"repository"`
+	Labels struct {
+		Nodes []struct {
+			Name string `json:"name"`
+		} `json:"nodes"`
+	} `json:"labels"`
+	Comments struct {
+		PageInfo graphqlPageInfo   `json:"pageInfo"`
+		Nodes    []*graphqlComment `json:"nodes"`
+	} `json:"comments"`
+	Reviews struct {
+		PageInfo graphqlPageInfo `json:"pageInfo"`
+		Nodes    []struct {
+			DatabaseID  int64         `json:"databaseId"`
+			State       string        `json:"state"`
+			Body        string        `json:"body"`
+			URL         string        `json:"url"`
+			SubmittedAt *time.Time    `json:"submittedAt"`
+			Author      *graphqlActor `json:"author"`
+			Comments    struct {
+				PageInfo graphqlPageInfo   `json:"pageInfo"`
+				Nodes    []*graphqlComment `json:"nodes"`
+			} `json:"comments"`
+		} `json:"nodes"`
+	} `json:"reviews"`
+	Files struct {
+		PageInfo graphqlPageInfo `json:"pageInfo"`
+		Nodes    []struct {
+			Path       string `json:"path"`
+			Additions  int    `json:"additions"`
+			Deletions  int    `json:"deletions"`
+			ChangeType string `json:"changeType"`
+		} `json:"nodes"`
+	} `json:"files"`
+}
+
+func (n *graphqlIssueOrPR) ownerRepo() (owner, repo string) {
+	parts := strings.SplitN(n.Repository.NameWithOwner, "/", 2)
+	if len(parts) != 2 {
+		return n.Repository.NameWithOwner, ""
+	}
+	return parts[0], parts[1]
+}
+
+// state returns the REST state, merged pull requests are closed.
+func (n *graphqlIssueOrPR) state() string {
+	if n.State == "OPEN" {
+		return "open"
+	}
+	return "closed"
+}
+
+func (n *graphqlIssueOrPR) labels() []*github.Label {
+	labels := make([]*github.Label, 0, len(n.Labels.Nodes))
+	for _, label := range n.Labels.Nodes {
+		labels = append(labels, &github.Label{Name: github.String(label.Name)})
+	}
+	return labels
+}
+
+func (n *graphqlIssueOrPR) toIssue() *github.Issue {
+	owner, repo := n.ownerRepo()
+	repoURL := fmt.Sprintf("https://api.github.com/repos/%s/%s", owner, repo)
+	issue := &github.Issue{
+		Number:        github.Int(n.Number),
+		Title:         github.String(n.Title),
+		State:         github.String(n.state()),
+		HTMLURL:       github.String(n.URL),
+		RepositoryURL: github.String(repoURL),
+		User:          n.Author.toUser(),
+		Labels:        n.labels(),
+		CreatedAt:     timePtr(n.CreatedAt),
+		UpdatedAt:     timePtr(n.UpdatedAt),
+		ClosedAt:      n.ClosedAt,
+	}
+	if n.Typename == "PullRequest" {
+		issue.PullRequestLinks = &github.PullRequestLinks{
+			URL:     github.String(fmt.Sprintf("%s/pulls/%d", repoURL, n.Number)),
+			HTMLURL: github.String(n.URL),
+		}
+	}
+	return issue
+}
+
+func (n *graphqlIssueOrPR) toPullRequest() *github.PullRequest {
+	owner, repo := n.ownerRepo()
+	return &github.PullRequest{
+		Number:    github.Int(n.Number),
+		Title:     github.String(n.Title),
+		State:     github.String(n.state()),
+		URL:       github.String(fmt.Sprintf("https://api.github.com/repos/%s/%s/pulls/%d", owner, repo, n.Number)),
+		HTMLURL:   github.String(n.URL),
+		User:      n.Author.toUser(),
+		Labels:    n.labels(),
+		Draft:     github.Bool(n.IsDraft),
+		Additions: github.Int(n.Additions),
+		Deletions: github.Int(n.Deletions),
+		CreatedAt: timePtr(n.CreatedAt),
+		UpdatedAt: timePtr(n.UpdatedAt),
+		ClosedAt:  n.ClosedAt,
+		MergedAt:  n.MergedAt,
+	}
+}
@@ -241,6 +241,25 @@ PAGINATION:
 	return prs, nil
 }
 
+// PullRequestsGet wraps PullRequests.Get, supports rate limit.
+func PullRequestsGet(
+	ctx context.Context, client *github.Client, owner, repo string, number int,
+) (*github.PullRequest, error) {
+	for {
+		pr, resp, err := client.PullRequests.Get(ctx, owner, repo, number)
+		if rateLimited, err := handleAPIError(err); err != nil {
+			return nil, err
+		} else if rateLimited {
+			continue
+		}
+		if resp.StatusCode != http.StatusOK {
+			body, _ := ioutil.ReadAll(resp.Body)
+			return nil, fmt.Errorf("pull request get error [%d] %s", resp.StatusCode, string(body))
+		}
+		return pr, nil
+	}
+}
+
 // PullRequestsListFiles wraps PullRequests.ListFiles,
 // supports pagination and rate limit.
 func PullRequestsListFiles(
@@ -274,6 +293,66 @@ PAGINATION:
 	return files, nil
 }
 
+// RepositoriesGetFile wraps Repositories.GetContents, supports rate
+// limit. It returns the content of the file at ref, or "" if the file does
+// not exist.
+func RepositoriesGetFile(
+	ctx context.Context, client *github.Client, owner, repo, path, ref string,
+) (string, error) {
+	opts := &github.RepositoryContentGetOptions{Ref: ref}
+	for {
+		file, _, resp, err := client.Repositories.GetContents(ctx, owner, repo, path, opts)
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			return "", nil
+		}
+		if rateLimited, err := handleAPIError(err); err != nil {
+			return "", err
+		} else if rateLimited {
+			continue
+		}
+		if resp.StatusCode != http.StatusOK {
+			body, _ := ioutil.ReadAll(resp.Body)
+			return "", fmt.Errorf("repository get contents error [%d] %s", resp.StatusCode, string(body))
+		}
+		if file == nil {
+			return "", fmt.Errorf("repository get contents error, %s is a directory", path)
+		}
+		return file.GetContent()
+	}
+}
+
+// TeamsListMembers wraps Teams.ListTeamMembersBySlug,
+// supports pagination and rate limit.
+func TeamsListMembers(
+	ctx context.Context, client *github.Client, org, slug string,
+) ([]*github.User, error) {
+	users := make([]*github.User, 0)
+	opts := &github.TeamListTeamMembersOptions{}
+PAGINATION:
+	for {
+	RATELIMIT:
+		for {
+			result, resp, err := client.Teams.ListTeamMembersBySlug(ctx, org, slug, opts)
+			if rateLimited, err := handleAPIError(err); err != nil {
+				return nil, err
+			} else if rateLimited {
+				continue
+			}
+			if resp.StatusCode != http.StatusOK {
+				body, _ := ioutil.ReadAll(resp.Body)
+				return nil, fmt.Errorf("team list members error [%d] %s", resp.StatusCode, string(body))
+			}
+			users = append(users, result...)
+			if resp.NextPage == 0 {
+				break PAGINATION
+			}
+			opts.Page = resp.NextPage
+			break RATELIMIT
+		}
+	}
+	return users, nil
+}
+
 func handleAPIError(err error) (rateLimited bool, e error) {
 	if err == nil {
 		return false, nil
@@ -284,5 +363,15 @@ func handleAPIError(err error) (rateLimited bool, e error) {
 		time.Sleep(dur)
 		return true, nil
 	}
+	// Concurrent requests may trigger secondary rate limits.
+	if abuse, ok := err.(*github.AbuseRateLimitError); ok {
+		dur := time.Minute
+		if abuse.RetryAfter != nil {
+			dur = *abuse.RetryAfter
+		}
+		log.Warnf("hit secondary rate limit, sleep %s", dur)
+		time.Sleep(dur)
+		return true, nil
+	}
 	return false, err
 }
@@ -0,0 +1,182 @@
+// Copyright 2021 ghstats Project Authors. Licensed under MIT.
+
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Type is the type of a metric family.
+type Type string
+
+const (
+	// Gauge is a value that can go up and down.
+	Gauge Type = "gauge"
+	// Counter is a value that only goes up.
+	Counter Type = "counter"
+	// Histogram is a distribution of observations.
+	Histogram Type = "histogram"
+)
+
+// Labels of a sample, they are written in the order of names.
+type Labels map[string]string
+
+// Family is a named group of samples.
+type Family struct {
+	Name    string
+	Help    string
+	Type    Type
+	Samples []Sample
+}
+
+// Sample is a value of a family, suffix is appended to the family name,
+// e.g. _bucket of histograms.
+type Sample struct {
+	Suffix string
+	Labels Labels
+	Value  float64
+}
+
+// Add appends a sample.
+func (f *Family) Add(labels Labels, value float64) {
+	f.Samples = append(f.Samples, Sample{Labels: labels, Value: value})
+}
+
+// AddHistogram appends _bucket, _sum and _count samples of a histogram.
+func (f *Family) AddHistogram(labels Labels, h *Buckets) {
+	count := uint64(0)
+	for i, le := range h.bounds {
+		count += h.counts[i]
+		f.Samples = append(f.Samples, Sample{
+			Suffix: "_bucket", Labels: withLabel(labels, "le", formatFloat(le)), Value: float64(count),
+		})
+	}
+	f.Samples = append(f.Samples,
+		Sample{Suffix: "_bucket", Labels: withLabel(labels, "le", "+Inf"), Value: float64(h.count)},
+		Sample{Suffix: "_sum", Labels: labels, Value: h.sum},
+		Sample{Suffix: "_count", Labels: labels, Value: float64(h.count)},
+	)
+}
+
+func withLabel(labels Labels, name, value string) Labels {
+	l := make(Labels, len(labels)+1)
+	for k, v := range labels {
+		l[k] = v
+	}
+	l[name] = value
+	return l
+}
+
+// Buckets counts observations of a histogram.
+type Buckets struct {
+	bounds []float64
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// NewBuckets returns a histogram with the given upper bounds, which must
+// be sorted in increasing order.
+func NewBuckets(bounds []float64) *Buckets {
+	return &Buckets{bounds: bounds, counts: make([]uint64, len(bounds))}
+}
+
+// Observe adds an observation.
+func (h *Buckets) Observe(v float64) {
+	h.sum += v
+	h.count++
+	for i, le := range h.bounds {
+		if v <= le {
+			h.counts[i]++
+			return
+		}
+	}
+}
+
+// Write writes families in the Prometheus text format.
+//
+// Source: https://prometheus.io/docs/instrumenting/exposition_formats/#text-based-format
+func Write(w io.Writer, families []Family) error {
+	bw := bufio.NewWriter(w)
+	for _, f := range families {
+		fmt.Fprintf(bw, "# HELP %s %s\n", f.Name, helpEscaper.Replace(f.Help))
+		fmt.Fprintf(bw, "# TYPE %s %s\n", f.Name, f.Type)
+		for _, s := range f.Samples {
+			bw.WriteString(f.Name)
+			bw.WriteString(s.Suffix)
+			writeLabels(bw, s.Labels)
+			bw.WriteString(" ")
+			bw.WriteString(formatFloat(s.Value))
+			bw.WriteString("\n")
+		}
+	}
+	return bw.Flush()
+}
+
+var (
+	helpEscaper  = strings.NewReplacer("\\", "\\\\", "\n", "\\n")
+	labelEscaper = strings.NewReplacer("\\", "\\\\", "\n", "\\n", "\"", "\\\"")
+)
+
+func writeLabels(w *bufio.Writer, labels Labels) {
+	if len(labels) == 0 {
+		return
+	}
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	w.WriteString("{")
+	for i, name := range names {
+		if i != 0 {
+			w.WriteString(",")
+		}
+		fmt.Fprintf(w, "%s=\"%s\"", name, labelEscaper.Replace(labels[name]))
+	}
+	w.WriteString("}")
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// Registry serves the latest families of a collection.
+type Registry struct {
+	mu       sync.RWMutex
+	families []Family
+}
+
+// Set replaces all families.
+func (r *Registry) Set(families []Family) {
+	r.mu.Lock()
+	r.families = families
+	r.mu.Unlock()
+}
+
+// ServeHTTP implements http.Handler.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.RLock()
+	families := r.families
+	r.mu.RUnlock()
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if err := Write(w, families); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,228 @@
+// Copyright 2021 ghstats Project Authors. Licensed under MIT.
+
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/overvenus/ghstats/pkg/config"
+	"github.com/overvenus/ghstats/pkg/feishu"
+	"github.com/overvenus/ghstats/pkg/report"
+)
+
+// Severity describes how urgent a report is, sinks map it to their colors.
+type Severity int
+
+const (
+	// SeverityInfo is for regular reports.
+	SeverityInfo Severity = iota
+	// SeveritySuccess is for good news, e.g. rankings.
+	SeveritySuccess
+	// SeverityWarning is for reports that need attention.
+	SeverityWarning
+	// SeverityDanger is for reports that need actions right now.
+	SeverityDanger
+)
+
+// Message is a report sent by notifiers, each sink renders the report
+// in its own format.
+type Message struct {
+	Report   *report.Report
+	Severity Severity
+}
+
+// Notifier sends reports to a destination.
+type Notifier interface {
+	Notify(ctx context.Context, msg Message) error
+}
+
+// ErrUnknownUser is returned by UserNotifier if the user has no account
+// in the chat tool.
+var ErrUnknownUser = errors.New("unknown user")
+
+// UserNotifier sends reports to GitHub users in person.
+type UserNotifier interface {
+	// NotifyUser returns ErrUnknownUser if the user is not configured.
+	NotifyUser(ctx context.Context, login string, msg Message) error
+}
+
+// Options are shared by notifiers of all sinks.
+type Options struct {
+	// Reports are only printed locally if it is true.
+	IsTest bool
+	// Timeout and retries of Feishu webhook bots, and credentials of the
+	// Feishu app.
+	Feishu config.Feishu
+	// Accounts of GitHub users, so that they are mentioned in chat tools.
+	Users []config.User
+}
+
+// New returns a notifier of a sink.
+func New(sink config.Sink, opts Options) (Notifier, error) {
+	switch sink.Type {
+	case "feishu":
+		return &feishuNotifier{
+			bot: feishu.WebhookBot{
+				Token:   sink.FeishuWebhookToken,
+				Secret:  sink.FeishuWebhookSecret,
+				IsTest:  opts.IsTest,
+				Client:  &http.Client{Timeout: opts.Feishu.Timeout},
+				Retries: opts.Feishu.Retries,
+			},
+			mention: feishuMention(opts.Users),
+		}, nil
+	case "feishu-app":
+		if len(sink.FeishuChatID) == 0 {
+			return nil, fmt.Errorf("feishu-chat-id of feishu-app notifier is not set")
+		}
+		app, err := newFeishuApp(opts)
+		if err != nil {
+			return nil, err
+		}
+		return &feishuAppNotifier{
+			app:     app,
+			chatID:  sink.FeishuChatID,
+			mention: feishuMention(opts.Users),
+		}, nil
+	case "slack":
+		return &slackNotifier{url: sink.SlackWebhookURL, isTest: opts.IsTest}, nil
+	case "file":
+		if len(sink.Path) == 0 {
+			return nil, fmt.Errorf("path of file notifier is not set")
+		}
+		return &fileNotifier{path: sink.Path}, nil
+	default:
+		return nil, fmt.Errorf("unknown notifier type %q, must be feishu, feishu-app, slack or file", sink.Type)
+	}
+}
+
+// NewUserNotifier returns a notifier that sends reports to users by their
+// own notifiers, or by the Feishu app if they have none.
+func NewUserNotifier(opts Options) (UserNotifier, error) {
+	n := &userNotifier{
+		users:     make(map[string]config.User, len(opts.Users)),
+		notifiers: make(map[string]Notifier),
+	}
+	for _, u := range opts.Users {
+		login := strings.ToLower(u.GitHub)
+		n.users[login] = u
+		if len(u.Notifiers) == 0 {
+			continue
+		}
+		notifier, err := FromConfig(u.Notifiers, "", config.Access{}, opts)
+		if err != nil {
+			return nil, err
+		}
+		n.notifiers[login] = notifier
+	}
+	if len(opts.Feishu.AppID) != 0 {
+		app, err := newFeishuApp(opts)
+		if err != nil {
+			return nil, err
+		}
+		n.app = app
+	}
+	if n.app == nil && len(n.notifiers) == 0 {
+		return nil, fmt.Errorf("no user could be notified, set app-id and app-secret of feishu, or notifiers of users")
+	}
+	return n, nil
+}
+
+// feishuAppKey is the config of a Feishu app.
+type feishuAppKey struct {
+	id, secret string
+	isTest     bool
+	timeout    time.Duration
+	retries    int
+}
+
+var (
+	feishuAppsMu sync.Mutex
+	// Feishu apps are shared by notifiers of the same config, so that the
+	// tenant access token is cached across notifiers and bot commands.
+	feishuApps = make(map[feishuAppKey]*feishu.App)
+)
+
+// newFeishuApp returns the Feishu app of the config in opts, it is created
+// once and shared.
+func newFeishuApp(opts Options) (*feishu.App, error) {
+	if len(opts.Feishu.AppID) == 0 || len(opts.Feishu.AppSecret) == 0 {
+		return nil, fmt.Errorf("app-id and app-secret of feishu are not set")
+	}
+	key := feishuAppKey{
+		id:      opts.Feishu.AppID,
+		secret:  opts.Feishu.AppSecret,
+		isTest:  opts.IsTest,
+		timeout: opts.Feishu.Timeout,
+		retries: opts.Feishu.Retries,
+	}
+	feishuAppsMu.Lock()
+	defer feishuAppsMu.Unlock()
+	if app, ok := feishuApps[key]; ok {
+		return app, nil
+	}
+	app := &feishu.App{
+		ID:      key.id,
+		Secret:  key.secret,
+		IsTest:  key.isTest,
+		Client:  &http.Client{Timeout: key.timeout},
+		Retries: key.retries,
+	}
+	feishuApps[key] = app
+	return app, nil
+}
+
+// FromConfig returns a notifier that sends reports to all enabled sinks.
+// If no sink is configured, reports are sent to the legacy notifier with
+// tokens in access.
+func FromConfig(
+	sinks []config.Sink, legacy string, access config.Access, opts Options,
+) (Notifier, error) {
+	if len(sinks) == 0 {
+		if len(legacy) == 0 {
+			legacy = "feishu"
+		}
+		sinks = []config.Sink{{
+			Type:                legacy,
+			Enable:              true,
+			FeishuWebhookToken:  access.FeishuWebhookToken,
+			FeishuWebhookSecret: access.FeishuWebhookSecret,
+			SlackWebhookURL:     access.SlackWebhookURL,
+		}}
+	}
+	notifiers := make(multiNotifier, 0, len(sinks))
+	for _, sink := range sinks {
+		if !sink.Enable {
+			continue
+		}
+		n, err := New(sink, opts)
+		if err != nil {
+			return nil, err
+		}
+		notifiers = append(notifiers, n)
+	}
+	return notifiers, nil
+}
+
+// multiNotifier fans out reports to several notifiers, a failed sink does
+// not stop others.
+type multiNotifier []Notifier
+
+func (m multiNotifier) Notify(ctx context.Context, msg Message) error {
+	errs := make([]string, 0)
+	for _, n := range m {
+		if err := n.Notify(ctx, msg); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) != 0 {
+		return fmt.Errorf("notify %q failed: %s", msg.Report.Title, strings.Join(errs, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+// Copyright 2021 ghstats Project Authors. Licensed under MIT.
+
+package notify
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/overvenus/ghstats/pkg/config"
+	"github.com/overvenus/ghstats/pkg/feishu"
+	"github.com/overvenus/ghstats/pkg/report"
+	"github.com/overvenus/ghstats/pkg/slack"
+)
+
+type feishuNotifier struct {
+	bot     feishu.WebhookBot
+	mention report.Mention
+}
+
+// Notify sends the report in numbered cards if it is too large for one.
+// Reports of issues and PRs with authors are sent in rich cards.
+func (n *feishuNotifier) Notify(ctx context.Context, msg Message) error {
+	if msg.Report.HasAuthors() {
+		sections := report.LarkCardSections(msg.Report, n.mention)
+		return n.bot.SendCardSections(ctx, msg.Report.Title, sections, feishuColor(msg.Severity))
+	}
+	sections := make([]feishu.Section, 0)
+	for _, s := range report.LarkSections(msg.Report, n.mention) {
+		sections = append(sections, feishu.Section{Header: s.Header, Blocks: s.Rows})
+	}
+	return n.bot.SendSections(ctx, msg.Report.Title, sections, feishuColor(msg.Severity))
+}
+
+// feishuAppNotifier sends reports to a chat by the Feishu app.
+type feishuAppNotifier struct {
+	app     *feishu.App
+	chatID  string
+	mention report.Mention
+}
+
+// Notify sends the report in numbered cards if it is too large for one.
+func (n *feishuAppNotifier) Notify(ctx context.Context, msg Message) error {
+	sections := report.LarkCardSections(msg.Report, n.mention)
+	return n.app.SendCardSections(
+		ctx, feishu.ReceiveChatID, n.chatID, msg.Report.Title, sections, feishuColor(msg.Severity))
+}
+
+// userNotifier sends reports to users by their own notifiers or the
+// Feishu app, users are keyed by lower case GitHub logins.
+type userNotifier struct {
+	users     map[string]config.User
+	notifiers map[string]Notifier
+	// app is nil if the Feishu app is not configured.
+	app *feishu.App
+}
+
+// NotifyUser sends the report by notifiers of the user, or by the Feishu
+// app to the first of open_id, user_id and email that is set.
+func (n *userNotifier) NotifyUser(ctx context.Context, login string, msg Message) error {
+	if notifier, ok := n.notifiers[strings.ToLower(login)]; ok {
+		return notifier.Notify(ctx, msg)
+	}
+	u := n.users[strings.ToLower(login)]
+	idType, id := feishu.ReceiveOpenID, u.FeishuOpenID
+	switch {
+	case n.app == nil:
+		return fmt.Errorf("%w %s, notifiers of the user are not set", ErrUnknownUser, login)
+	case len(u.FeishuOpenID) != 0:
+	case len(u.FeishuUserID) != 0:
+		idType, id = feishu.ReceiveUserID, u.FeishuUserID
+	case len(u.Email) != 0:
+		idType, id = feishu.ReceiveEmail, u.Email
+	default:
+		return fmt.Errorf("%w %s", ErrUnknownUser, login)
+	}
+	sections := report.LarkCardSections(msg.Report, nil)
+	return n.app.SendCardSections(ctx, idType, id, msg.Report.Title, sections, feishuColor(msg.Severity))
+}
+
+// feishuMention returns the <at> tag of users, GitHub logins are case
+// insensitive.
+//
+// Source: https://open.feishu.cn/document/ukTMukTMukTM/uADOwUjLwgDM14CM4ATN
+func feishuMention(users []config.User) report.Mention {
+	byLogin := make(map[string]config.User, len(users))
+	for _, u := range users {
+		byLogin[strings.ToLower(u.GitHub)] = u
+	}
+	return func(login string) string {
+		u := byLogin[strings.ToLower(login)]
+		switch {
+		case len(u.FeishuOpenID) != 0:
+			return fmt.Sprintf("<at id=%s></at>", u.FeishuOpenID)
+		case len(u.FeishuUserID) != 0:
+			return fmt.Sprintf("<at id=%s></at>", u.FeishuUserID)
+		case len(u.Email) != 0:
+			return fmt.Sprintf("<at email=%s></at>", u.Email)
+		}
+		return ""
+	}
+}
+
+func feishuColor(s Severity) feishu.TitleColor {
+	switch s {
+	case SeveritySuccess:
+		return feishu.TitleColorGreen
+	case SeverityWarning:
+		return feishu.TitleColorOrange
+	case SeverityDanger:
+		return feishu.TitleColorRed
+	default:
+		return feishu.TitleColorWathet
+	}
+}
+
+type slackNotifier struct {
+	url    string
+	isTest bool
+}
+
+func (n *slackNotifier) Notify(ctx context.Context, msg Message) error {
+	bot := slack.WebhookBot{URL: n.url, IsTest: n.isTest}
+	return bot.SendMarkdownMessage(ctx, msg.Report.Title, report.LarkMarkdown(msg.Report), slackColor(msg.Severity))
+}
+
+func slackColor(s Severity) slack.Color {
+	switch s {
+	case SeveritySuccess:
+		return slack.ColorGreen
+	case SeverityWarning:
+		return slack.ColorOrange
+	case SeverityDanger:
+		return slack.ColorRed
+	default:
+		return slack.ColorBlue
+	}
+}
+
+// fileNotifier appends reports to a markdown file, each report is
+// preceded by a comment of the time it is sent.
+type fileNotifier struct {
+	path string
+	mu   sync.Mutex
+}
+
+func (n *fileNotifier) Notify(ctx context.Context, msg Message) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	f, err := os.OpenFile(n.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(f, "<!-- %s -->\n%s\n", time.Now().Format(time.RFC3339), report.Markdown(msg.Report))
+	if err1 := f.Close(); err == nil {
+		err = err1
+	}
+	return err
+}
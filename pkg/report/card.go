@@ -0,0 +1,104 @@
+// Copyright 2021 ghstats Project Authors. Licensed under MIT.
+
+package report
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/overvenus/ghstats/pkg/feishu"
+	"github.com/overvenus/ghstats/pkg/markdown"
+)
+
+// HasAuthors returns whether any row has an author, such reports are
+// rendered as rich cards by LarkCardSections.
+func (r *Report) HasAuthors() bool {
+	for _, s := range r.Sections {
+		for _, row := range s.Rows {
+			if len(row.Author) != 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// LarkCardSections renders the report as Feishu card sections. Rows with
+// authors are column sets of the link and title, the author and metrics,
+// followed by a button that opens the link. Other rows are lark_md as in
+// LarkMarkdown. Sections are separated by dividers, and a section with
+// a URL has a button that opens it. Users are mentioned as in
+// LarkSections.
+func LarkCardSections(r *Report, mention Mention) []feishu.CardSection {
+	sections := make([]feishu.CardSection, 0, len(r.Sections)+2)
+	if r.IsEmpty() {
+		sections = append(sections, feishu.CardSection{
+			Blocks: [][]feishu.Element{{feishu.Markdown(r.Empty)}},
+		})
+	}
+	for _, s := range r.Sections {
+		if len(s.Rows) == 0 {
+			continue
+		}
+		section := feishu.CardSection{}
+		if len(sections) != 0 {
+			section.Header = append(section.Header, feishu.Divider())
+		}
+		if len(s.Title) != 0 {
+			section.Header = append(section.Header, feishu.Markdown(fmt.Sprintf("**%s**", sectionTitle(s))))
+		}
+		if len(s.URL) != 0 {
+			section.Header = append(section.Header, feishu.Actions(feishu.Button{
+				Text: "Open in GitHub search", URL: s.URL,
+			}))
+		}
+		for _, row := range s.Rows {
+			section.Blocks = append(section.Blocks, cardRow(row, mention))
+		}
+		sections = append(sections, section)
+	}
+	if tr := r.TimeRange(); len(tr) != 0 {
+		sections = append(sections, feishu.CardSection{
+			Blocks: [][]feishu.Element{{feishu.Note(tr)}},
+		})
+	}
+	return sections
+}
+
+func cardRow(row Row, mention Mention) []feishu.Element {
+	if len(row.Author) == 0 {
+		return []feishu.Element{feishu.Markdown(strings.TrimRight(larkRow(row, mention), "\n"))}
+	}
+	link := markdown.Escape(row.Ref)
+	if len(row.URL) != 0 {
+		link = markdown.Link(row.Ref, row.URL)
+	}
+	columns := []feishu.Column{
+		{Weight: 5, Elements: []feishu.Element{
+			feishu.Markdown(strings.TrimSpace(link + " " + markdown.Escape(row.Title))),
+		}},
+		{Weight: 2, Elements: []feishu.Element{
+			feishu.Markdown(markdown.Escape(row.Author)),
+		}},
+	}
+	for _, m := range row.Metrics {
+		columns = append(columns, feishu.Column{Weight: 1, Elements: []feishu.Element{
+			feishu.Markdown(markdown.Escape(fmt.Sprintf("%s: %s", m.Name, m))),
+		}})
+	}
+	elements := []feishu.Element{feishu.Columns(columns...)}
+	if len(row.Mentions) != 0 {
+		elements = append(elements, feishu.Markdown(larkMentions(row.Mentions, mention)))
+	}
+	if len(row.Note) != 0 {
+		elements = append(elements, feishu.Note(markdown.Escape(row.Note)))
+	}
+	if len(row.URL) != 0 {
+		text := "Open issue"
+		if strings.Contains(row.URL, "/pull/") {
+			text = "Open PR"
+		}
+		elements = append(elements, feishu.Actions(feishu.Button{Text: text, URL: row.URL}))
+	}
+	return elements
+}
@@ -0,0 +1,216 @@
+// Copyright 2021 ghstats Project Authors. Licensed under MIT.
+
+package report
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// WriteHTML writes the report as a self-contained HTML page, styles and
+// charts are inlined so that it could be attached or published as is.
+// The first section is the headline, e.g. a leaderboard, charts follow it.
+func WriteHTML(w io.Writer, r *Report) error {
+	page := htmlPage{Title: r.Title, TimeRange: r.TimeRange()}
+	if r.IsEmpty() {
+		page.Empty = r.Empty
+	}
+	headline := true
+	for _, s := range r.Sections {
+		if len(s.Rows) == 0 {
+			continue
+		}
+		t := htmlTable(s)
+		if headline {
+			page.Headline = append(page.Headline, t)
+			headline = false
+			continue
+		}
+		page.Tables = append(page.Tables, t)
+	}
+	for _, c := range r.Charts {
+		if len(c.Points) == 0 {
+			continue
+		}
+		svg := barSVG(c.Points)
+		if c.Kind == ChartTimeline {
+			svg = timelineSVG(c.Points)
+		}
+		page.Charts = append(page.Charts, htmlChart{Title: c.Title, SVG: svg})
+	}
+	return htmlTemplate.Execute(w, page)
+}
+
+type htmlPage struct {
+	Title     string
+	TimeRange string
+	Empty     string
+	Headline  []htmlTableData
+	Charts    []htmlChart
+	Tables    []htmlTableData
+}
+
+type htmlTableData struct {
+	Title   string
+	URL     string
+	Headers []string
+	Rows    [][]htmlCell
+}
+
+type htmlCell struct {
+	Text string
+	URL  string
+	Num  bool
+}
+
+type htmlChart struct {
+	Title string
+	SVG   template.HTML
+}
+
+func htmlTable(s Section) htmlTableData {
+	cols := columns(s.Rows, false)
+	t := htmlTableData{Title: s.Title, URL: s.URL, Headers: make([]string, len(cols))}
+	for i, col := range cols {
+		t.Headers[i] = col.header
+	}
+	for _, row := range s.Rows {
+		cells := make([]htmlCell, len(cols))
+		for i, col := range cols {
+			cells[i] = htmlCell{
+				Text: col.cell(row),
+				Num:  len(col.metricName) != 0 || col.header == "#" || col.header == "Score",
+			}
+			if col.header == "Ref" {
+				cells[i].URL = row.URL
+			}
+		}
+		t.Rows = append(t.Rows, cells)
+	}
+	return t
+}
+
+var htmlTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; color: #24292e; max-width: 960px; margin: 2em auto; padding: 0 1em; }
+h1 { font-size: 1.6em; }
+h2 { font-size: 1.2em; border-bottom: 1px solid #eaecef; padding-bottom: .3em; margin-top: 2em; }
+table { border-collapse: collapse; width: 100%; font-size: 14px; }
+th, td { border: 1px solid #dfe2e5; padding: 4px 8px; text-align: left; }
+th { background: #f6f8fa; }
+td.num { text-align: right; font-variant-numeric: tabular-nums; }
+a { color: #0366d6; text-decoration: none; }
+.muted { color: #6a737d; }
+svg { max-width: 100%; height: auto; }
+svg text { font-size: 12px; fill: #24292e; }
+svg .bar { fill: #2ea44f; }
+svg .col { fill: #0366d6; }
+svg .axis { stroke: #d1d5da; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+{{with .TimeRange}}<p class="muted">{{.}}</p>
+{{end}}{{with .Empty}}<p class="muted">{{.}}</p>
+{{end}}{{range .Headline}}{{template "table" .}}{{end}}
+{{range .Charts}}<h2>{{.Title}}</h2>
+{{.SVG}}
+{{end}}{{range .Tables}}{{template "table" .}}{{end}}
+</body>
+</html>
+{{define "table"}}{{if .Title}}<h2>{{if .URL}}<a href="{{.URL}}">{{.Title}}</a>{{else}}{{.Title}}{{end}}</h2>
+{{end}}<table>
+<thead><tr>{{range .Headers}}<th>{{.}}</th>{{end}}</tr></thead>
+<tbody>
+{{range .Rows}}<tr>{{range .}}<td{{if .Num}} class="num"{{end}}>{{if .URL}}<a href="{{.URL}}">{{.Text}}</a>{{else}}{{.Text}}{{end}}</td>{{end}}</tr>
+{{end}}</tbody>
+</table>
+{{end}}`))
+
+const (
+	svgWidth     = 720
+	barLabelW    = 200
+	barRowH      = 24
+	timelineH    = 160
+	timelineMinW = 40
+)
+
+// barSVG draws a horizontal bar for each point.
+func barSVG(points []Point) template.HTML {
+	max := maxValue(points)
+	barMax := float64(svgWidth - barLabelW - 60)
+	b := strings.Builder{}
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`,
+		svgWidth, len(points)*barRowH+8, svgWidth, len(points)*barRowH+8)
+	for i, p := range points {
+		y := i*barRowH + 4
+		w := p.Value / max * barMax
+		fmt.Fprintf(&b, `<text x="%d" y="%d" text-anchor="end">%s</text>`,
+			barLabelW-8, y+16, template.HTMLEscapeString(p.Label))
+		fmt.Fprintf(&b, `<rect class="bar" x="%d" y="%d" width="%.1f" height="%d"><title>%s: %s</title></rect>`,
+			barLabelW, y+3, w, barRowH-6, template.HTMLEscapeString(p.Label), formatValue(p.Value))
+		fmt.Fprintf(&b, `<text x="%.1f" y="%d">%s</text>`, float64(barLabelW)+w+6, y+16, formatValue(p.Value))
+	}
+	b.WriteString(`</svg>`)
+	return template.HTML(b.String())
+}
+
+// timelineSVG draws a column for each point, labels are thinned out so
+// that they do not overlap.
+func timelineSVG(points []Point) template.HTML {
+	max := maxValue(points)
+	colW := (svgWidth - 20) / len(points)
+	if colW > 48 {
+		colW = 48
+	}
+	if colW < 4 {
+		colW = 4
+	}
+	step := (timelineMinW + colW - 1) / colW
+	width := len(points)*colW + 20
+	base := timelineH + 20
+	b := strings.Builder{}
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`,
+		width, base+24, width, base+24)
+	fmt.Fprintf(&b, `<line class="axis" x1="10" y1="%d" x2="%d" y2="%d"/>`, base, width-10, base)
+	for i, p := range points {
+		x := 10 + i*colW
+		h := p.Value / max * timelineH
+		fmt.Fprintf(&b, `<rect class="col" x="%d" y="%.1f" width="%d" height="%.1f"><title>%s: %s</title></rect>`,
+			x+1, float64(base)-h, colW-2, h, template.HTMLEscapeString(p.Label), formatValue(p.Value))
+		if colW >= 24 && p.Value != 0 {
+			fmt.Fprintf(&b, `<text x="%d" y="%.1f" text-anchor="middle">%s</text>`,
+				x+colW/2, float64(base)-h-4, formatValue(p.Value))
+		}
+		if i%step == 0 {
+			fmt.Fprintf(&b, `<text x="%d" y="%d" text-anchor="middle">%s</text>`,
+				x+colW/2, base+16, template.HTMLEscapeString(p.Label))
+		}
+	}
+	b.WriteString(`</svg>`)
+	return template.HTML(b.String())
+}
+
+// maxValue returns the max value of points, it is at least 1 so that
+// it could be used as a divisor.
+func maxValue(points []Point) float64 {
+	max := 1.0
+	for _, p := range points {
+		if p.Value > max {
+			max = p.Value
+		}
+	}
+	return max
+}
+
+func formatValue(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
@@ -0,0 +1,378 @@
+// Copyright 2021 ghstats Project Authors. Licensed under MIT.
+
+package report
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/overvenus/ghstats/pkg/markdown"
+)
+
+// LarkMarkdown renders the report body as Feishu lark_md, the title is
+// rendered by card headers. Rows of subjects take two lines, one for the
+// name and one for metrics, rows of issues and PRs take one line.
+//
+//	## pingcap/tidb
+//	[#123](https://github.com/pingcap/tidb/pull/123) title \- size: 42
+//
+//	\#1 **user** \(12\.5\)
+//	LGTM: 3, PR comments: 5
+//
+//	[2021-05-23 21:00:00, 2021-05-24 21:00:00]
+func LarkMarkdown(r *Report) string {
+	buf := strings.Builder{}
+	for _, s := range LarkSections(r, nil) {
+		buf.WriteString(s.Header)
+		for _, row := range s.Rows {
+			buf.WriteString(row)
+		}
+	}
+	return buf.String()
+}
+
+// LarkSection is a section of the report body in lark_md, so that long
+// reports can be split on sections and rows, see LarkMarkdown.
+type LarkSection struct {
+	// Header is empty if the section has no title.
+	Header string
+	Rows   []string
+}
+
+// LarkSections renders the report body as lark_md sections, the empty
+// message and the time range are sections without header. Users are
+// mentioned by mention, or as plain "@login" if it is nil or returns "".
+func LarkSections(r *Report, mention Mention) []LarkSection {
+	sections := make([]LarkSection, 0, len(r.Sections)+2)
+	if r.IsEmpty() {
+		sections = append(sections, LarkSection{Rows: []string{r.Empty + "\n"}})
+	}
+	for _, s := range r.Sections {
+		if len(s.Rows) == 0 {
+			continue
+		}
+		section := LarkSection{Rows: make([]string, 0, len(s.Rows))}
+		if len(s.Title) != 0 {
+			section.Header = fmt.Sprintf("## %s\n", sectionTitle(s))
+		}
+		for _, row := range s.Rows {
+			section.Rows = append(section.Rows, larkRow(row, mention))
+		}
+		sections = append(sections, section)
+	}
+	if tr := r.TimeRange(); len(tr) != 0 {
+		sections = append(sections, LarkSection{Rows: []string{"\n" + tr}})
+	}
+	return sections
+}
+
+// sectionTitle returns the escaped title, which links to the URL if any.
+func sectionTitle(s Section) string {
+	if len(s.URL) != 0 {
+		return markdown.Link(s.Title, s.URL)
+	}
+	return markdown.Escape(s.Title)
+}
+
+// larkMentions returns mentions of logins separated by spaces.
+func larkMentions(logins []string, mention Mention) string {
+	parts := make([]string, 0, len(logins))
+	for _, login := range logins {
+		m := ""
+		if mention != nil {
+			m = mention(login)
+		}
+		if len(m) == 0 {
+			m = markdown.Escape("@" + login)
+		}
+		parts = append(parts, m)
+	}
+	return strings.Join(parts, " ")
+}
+
+func larkRow(row Row, mention Mention) string {
+	parts := make([]string, 0, 5)
+	if row.Rank > 0 {
+		parts = append(parts, markdown.Escape(fmt.Sprint("#", row.Rank)))
+	}
+	if len(row.URL) != 0 {
+		parts = append(parts, markdown.Link(row.Ref, row.URL))
+	} else if len(row.Ref) != 0 {
+		parts = append(parts, markdown.Escape(row.Ref))
+	}
+	if len(row.Name) != 0 {
+		parts = append(parts, fmt.Sprintf("**%s**", markdown.Escape(row.Name)))
+	}
+	if len(row.Title) != 0 {
+		parts = append(parts, markdown.Escape(row.Title))
+	}
+	if row.Score != nil {
+		parts = append(parts, markdown.Escape(fmt.Sprintf("(%g)", *row.Score)))
+	}
+	if len(row.Mentions) != 0 {
+		parts = append(parts, larkMentions(row.Mentions, mention))
+	}
+	line := strings.Join(parts, " ")
+
+	details := formatMetrics(row.Metrics)
+	if len(row.Author) != 0 {
+		details = strings.TrimSuffix("by "+row.Author+", "+details, ", ")
+	}
+	if len(row.Note) != 0 {
+		details = strings.TrimSpace(fmt.Sprintf("%s (%s)", details, row.Note))
+	}
+	if len(row.Name) != 0 {
+		return fmt.Sprintf("%s\n%s\n\n", line, markdown.Escape(details))
+	}
+	if len(details) != 0 {
+		line += " " + markdown.Escape("- "+details)
+	}
+	return line + "\n"
+}
+
+// formatMetrics formats metrics as "name: value, ...".
+func formatMetrics(metrics []Metric) string {
+	parts := make([]string, 0, len(metrics))
+	for _, m := range metrics {
+		parts = append(parts, fmt.Sprintf("%s: %s", m.Name, m))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Markdown renders the report as a markdown document, sections are
+// rendered as tables.
+func Markdown(r *Report) string {
+	buf := strings.Builder{}
+	buf.WriteString(fmt.Sprintf("# %s\n\n", markdown.Escape(r.Title)))
+	if tr := r.TimeRange(); len(tr) != 0 {
+		buf.WriteString(tr)
+		buf.WriteString("\n\n")
+	}
+	if r.IsEmpty() {
+		buf.WriteString(markdown.Escape(r.Empty))
+		buf.WriteString("\n")
+		return buf.String()
+	}
+	cellEscape := func(s string) string {
+		return strings.ReplaceAll(markdown.Escape(s), "|", "\\|")
+	}
+	for _, s := range r.Sections {
+		if len(s.Rows) == 0 {
+			continue
+		}
+		if len(s.Title) != 0 {
+			buf.WriteString(fmt.Sprintf("## %s\n\n", sectionTitle(s)))
+		}
+		cols := columns(s.Rows, false)
+		headers := make([]string, len(cols))
+		aligns := make([]string, len(cols))
+		for i, col := range cols {
+			headers[i] = cellEscape(col.header)
+			aligns[i] = "---"
+		}
+		buf.WriteString("| " + strings.Join(headers, " | ") + " |\n")
+		buf.WriteString("| " + strings.Join(aligns, " | ") + " |\n")
+		for _, row := range s.Rows {
+			cells := make([]string, len(cols))
+			for i, col := range cols {
+				if col.header == "Ref" && len(row.URL) != 0 {
+					cells[i] = strings.ReplaceAll(markdown.Link(row.Ref, row.URL), "|", "\\|")
+					continue
+				}
+				cells[i] = cellEscape(col.cell(row))
+			}
+			buf.WriteString("| " + strings.Join(cells, " | ") + " |\n")
+		}
+		buf.WriteString("\n")
+	}
+	return buf.String()
+}
+
+// WriteTable writes the report as plain text tables for terminals.
+func WriteTable(w io.Writer, r *Report) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, r.Title)
+	if tr := r.TimeRange(); len(tr) != 0 {
+		fmt.Fprintln(tw, tr)
+	}
+	if r.IsEmpty() {
+		fmt.Fprintln(tw, r.Empty)
+		return tw.Flush()
+	}
+	for _, s := range r.Sections {
+		if len(s.Rows) == 0 {
+			continue
+		}
+		fmt.Fprintln(tw)
+		if len(s.Title) != 0 {
+			fmt.Fprintf(tw, "== %s ==\n", s.Title)
+		}
+		cols := columns(s.Rows, false)
+		cells := make([]string, len(cols))
+		for i, col := range cols {
+			cells[i] = strings.ToUpper(col.header)
+		}
+		fmt.Fprintln(tw, strings.Join(cells, "\t"))
+		for _, row := range s.Rows {
+			for i, col := range cols {
+				cells[i] = col.cell(row)
+			}
+			fmt.Fprintln(tw, strings.Join(cells, "\t"))
+		}
+	}
+	return tw.Flush()
+}
+
+// JSON renders the report as indented JSON.
+func JSON(r *Report) ([]byte, error) {
+	type timeRange struct {
+		Start time.Time `json:"start"`
+		End   time.Time `json:"end"`
+	}
+	v := struct {
+		*Report
+		TimeRange *timeRange `json:"time_range,omitempty"`
+	}{Report: r}
+	if !r.Start.IsZero() || !r.End.IsZero() {
+		v.TimeRange = &timeRange{Start: r.Start, End: r.End}
+	}
+	return json.MarshalIndent(v, "", "  ")
+}
+
+// WriteCSV writes rows of all sections as one CSV table, the first column
+// is the section title and metrics are plain numbers, durations are in
+// seconds.
+func WriteCSV(w io.Writer, r *Report) error {
+	rows := make([]Row, 0)
+	for _, s := range r.Sections {
+		rows = append(rows, s.Rows...)
+	}
+	cols := columns(rows, true)
+	cw := csv.NewWriter(w)
+	record := make([]string, 0, len(cols)+1)
+	record = append(record, "Section")
+	for _, col := range cols {
+		record = append(record, col.header)
+	}
+	if err := cw.Write(record); err != nil {
+		return err
+	}
+	for _, s := range r.Sections {
+		for _, row := range s.Rows {
+			record = append(record[:0], s.Title)
+			for _, col := range cols {
+				if m, ok := col.metric(row); ok {
+					record = append(record, strconv.FormatFloat(m.Value, 'f', -1, 64))
+					continue
+				}
+				record = append(record, col.cell(row))
+			}
+			if err := cw.Write(record); err != nil {
+				return err
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+type column struct {
+	header string
+	cell   func(Row) string
+	// metric is the name of the metric of the column, if any.
+	metricName string
+}
+
+// metric returns the metric of the column in the row.
+func (c column) metric(r Row) (Metric, bool) {
+	if len(c.metricName) == 0 {
+		return Metric{}, false
+	}
+	for _, m := range r.Metrics {
+		if m.Name == c.metricName {
+			return m, true
+		}
+	}
+	return Metric{}, false
+}
+
+// columns returns columns used by any of rows, metrics are in the order
+// they first appear. URLs of links get their own column if url is true.
+func columns(rows []Row, url bool) []column {
+	var rank, name, ref, title, author, score, note, mentions bool
+	metrics := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, row := range rows {
+		rank = rank || row.Rank > 0
+		name = name || len(row.Name) != 0
+		ref = ref || len(row.Ref) != 0
+		title = title || len(row.Title) != 0
+		author = author || len(row.Author) != 0
+		score = score || row.Score != nil
+		note = note || len(row.Note) != 0
+		mentions = mentions || len(row.Mentions) != 0
+		for _, m := range row.Metrics {
+			if !seen[m.Name] {
+				seen[m.Name] = true
+				metrics = append(metrics, m.Name)
+			}
+		}
+	}
+	cols := make([]column, 0)
+	if rank {
+		cols = append(cols, column{header: "#", cell: func(r Row) string {
+			if r.Rank == 0 {
+				return ""
+			}
+			return fmt.Sprint(r.Rank)
+		}})
+	}
+	if name {
+		cols = append(cols, column{header: "Name", cell: func(r Row) string { return r.Name }})
+	}
+	if ref {
+		cols = append(cols, column{header: "Ref", cell: func(r Row) string { return r.Ref }})
+		if url {
+			cols = append(cols, column{header: "URL", cell: func(r Row) string { return r.URL }})
+		}
+	}
+	if title {
+		cols = append(cols, column{header: "Title", cell: func(r Row) string { return r.Title }})
+	}
+	if author {
+		cols = append(cols, column{header: "Author", cell: func(r Row) string { return r.Author }})
+	}
+	if score {
+		cols = append(cols, column{header: "Score", cell: func(r Row) string {
+			if r.Score == nil {
+				return ""
+			}
+			return fmt.Sprintf("%g", *r.Score)
+		}})
+	}
+	for _, name := range metrics {
+		col := column{header: name, metricName: name}
+		col.cell = func(r Row) string {
+			if m, ok := col.metric(r); ok {
+				return m.String()
+			}
+			return ""
+		}
+		cols = append(cols, col)
+	}
+	if note {
+		cols = append(cols, column{header: "Note", cell: func(r Row) string { return r.Note }})
+	}
+	if mentions {
+		cols = append(cols, column{header: "Mentions", cell: func(r Row) string {
+			return strings.Join(r.Mentions, " ")
+		}})
+	}
+	return cols
+}
@@ -0,0 +1,157 @@
+// Copyright 2021 ghstats Project Authors. Licensed under MIT.
+
+package report
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// TimeFormat is the format of report time ranges.
+const TimeFormat = "2006-01-02 15:04:05"
+
+// Report is the data of a report, commands build it and renderers format
+// it for chat tools, files and terminals.
+type Report struct {
+	Title    string    `json:"title"`
+	Sections []Section `json:"sections"`
+	// Charts are only drawn by renderers that support graphics.
+	Charts []Chart `json:"charts,omitempty"`
+	// Empty is shown instead of sections if there is no row.
+	Empty string `json:"empty,omitempty"`
+	// Time range the report covers, both are zero if it is a snapshot.
+	Start time.Time `json:"-"`
+	End   time.Time `json:"-"`
+}
+
+// Section is a group of rows, e.g. PRs of a repository.
+type Section struct {
+	// Title may be empty if the report has only one section.
+	Title string `json:"title,omitempty"`
+	// URL is a GitHub search that lists all rows of the section.
+	URL  string `json:"url,omitempty"`
+	Rows []Row  `json:"rows"`
+}
+
+// Row is a line of a report, it is about either a subject, e.g. a user
+// or a repository, or a linked issue or PR.
+type Row struct {
+	// Rank in a leaderboard, 0 means it is not ranked.
+	Rank int `json:"rank,omitempty"`
+	// Name of the subject, e.g. a user login.
+	Name string `json:"name,omitempty"`
+	// Link to an issue or a PR, Ref is its text, e.g. #123.
+	Ref string `json:"ref,omitempty"`
+	URL string `json:"url,omitempty"`
+	// Title and author of the linked issue or PR.
+	Title   string   `json:"title,omitempty"`
+	Author  string   `json:"author,omitempty"`
+	Score   *float64 `json:"score,omitempty"`
+	Metrics []Metric `json:"metrics,omitempty"`
+	// Note is a short remark, e.g. "waiting".
+	Note string `json:"note,omitempty"`
+	// GitHub logins of users to mention, e.g. requested reviewers.
+	Mentions []string `json:"mentions,omitempty"`
+}
+
+// Mention returns how a GitHub login is mentioned in a chat tool, e.g. a
+// Feishu <at> tag, or "" if the user has no account in the chat tool.
+type Mention func(login string) string
+
+// ChartKind is how a chart is drawn.
+type ChartKind string
+
+const (
+	// ChartBar draws horizontal bars, e.g. activities of each repository.
+	ChartBar ChartKind = "bar"
+	// ChartTimeline draws columns in order, e.g. activities of each day.
+	ChartTimeline ChartKind = "timeline"
+)
+
+// Chart is a series of labeled values.
+type Chart struct {
+	Title  string    `json:"title"`
+	Kind   ChartKind `json:"kind"`
+	Points []Point   `json:"points"`
+}
+
+// Point is a value of a chart.
+type Point struct {
+	Label string  `json:"label"`
+	Value float64 `json:"value"`
+}
+
+// Unit is the unit of a metric value.
+type Unit string
+
+const (
+	// UnitCount is for counts and other plain numbers.
+	UnitCount Unit = ""
+	// UnitSeconds is for durations.
+	UnitSeconds Unit = "seconds"
+)
+
+// Metric is a named value of a row.
+type Metric struct {
+	Name  string  `json:"name"`
+	Value float64 `json:"value"`
+	Unit  Unit    `json:"unit,omitempty"`
+}
+
+// Count returns a count metric.
+func Count(name string, n int) Metric {
+	return Metric{Name: name, Value: float64(n)}
+}
+
+// Duration returns a duration metric.
+func Duration(name string, d time.Duration) Metric {
+	return Metric{Name: name, Value: d.Seconds(), Unit: UnitSeconds}
+}
+
+// String formats the value, durations are formatted by FormatDuration.
+func (m Metric) String() string {
+	if m.Unit == UnitSeconds {
+		return FormatDuration(time.Duration(m.Value * float64(time.Second)))
+	}
+	return strconv.FormatFloat(m.Value, 'g', -1, 64)
+}
+
+// Score returns a pointer of the score for Row.Score.
+func Score(s float64) *float64 {
+	return &s
+}
+
+// IsEmpty returns whether the report has no row.
+func (r *Report) IsEmpty() bool {
+	for _, s := range r.Sections {
+		if len(s.Rows) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// TimeRange formats the time range, it is empty if the report is a snapshot.
+func (r *Report) TimeRange() string {
+	if r.Start.IsZero() && r.End.IsZero() {
+		return ""
+	}
+	return fmt.Sprintf("[%s, %s]", r.Start.Format(TimeFormat), r.End.Format(TimeFormat))
+}
+
+// FormatDuration formats a duration in days, hours and minutes, e.g. 1d2h.
+func FormatDuration(d time.Duration) string {
+	d = d.Round(time.Minute)
+	days := d / (24 * time.Hour)
+	hours := (d % (24 * time.Hour)) / time.Hour
+	minutes := (d % time.Hour) / time.Minute
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%dd%dh", days, hours)
+	case hours > 0:
+		return fmt.Sprintf("%dh%dm", hours, minutes)
+	default:
+		return fmt.Sprintf("%dm", minutes)
+	}
+}
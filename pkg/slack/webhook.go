@@ -0,0 +1,244 @@
+// Copyright 2021 ghstats Project Authors. Licensed under MIT.
+
+package slack
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// Color defines slack message attachment color.
+type Color string
+
+const (
+	// ColorBlue Blue
+	ColorBlue Color = "#1d9bd1"
+	// ColorGreen Green
+	ColorGreen Color = "#2eb886"
+	// ColorYellow Yellow
+	ColorYellow Color = "#daa038"
+	// ColorOrange Orange
+	ColorOrange Color = "#e8912d"
+	// ColorRed Red
+	ColorRed Color = "#e01e5a"
+	// ColorGrey Grey
+	ColorGrey Color = "#868686"
+)
+
+// Limits of Block Kit, see https://api.slack.com/reference/block-kit/blocks
+const (
+	maxHeaderLen  = 150
+	maxSectionLen = 3000
+	maxBlocks     = 50
+)
+
+// WebhookBot is a slack incoming webhook.
+type WebhookBot struct {
+	URL    string
+	IsTest bool // If it's true, we only print the message to local.
+}
+
+// SendMarkdownMessage sends markdown message via slack incoming webhook,
+// msg must be markdown escaped, it is rendered as Block Kit blocks:
+// "## " lines become headers, links and bold texts become mrkdwn.
+//
+//	{
+//	  "attachments": [
+//	    {
+//	      "color": "#2eb886",
+//	      "blocks": [
+//	        {"type": "header", "text": {"type": "plain_text", "text": "PTAL ❤️"}},
+//	        {"type": "section", "text": {"type": "mrkdwn", "text": "<url|#1> title"}}
+//	      ]
+//	    }
+//	  ]
+//	}
+//
+// Source: https://api.slack.com/messaging/webhooks
+func (bot WebhookBot) SendMarkdownMessage(ctx context.Context, title, msg string, color Color) error {
+	blocks := append([]block{headerBlock(title)}, markdownBlocks(msg)...)
+	if len(blocks) > maxBlocks {
+		blocks = append(blocks[:maxBlocks-1], sectionBlock("…"))
+	}
+	payload, err := json.Marshal(map[string]interface{}{
+		"text": title, // Fallback of notifications.
+		"attachments": []interface{}{
+			map[string]interface{}{"color": color, "blocks": blocks},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	if bot.IsTest {
+		fmt.Printf("Print messages locally only: %s\n", payload)
+		return nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, bot.URL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Add("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("slack send markdown error [%d] %s", resp.StatusCode, string(body))
+	}
+	return nil
+}
+
+type block struct {
+	Type string `json:"type"`
+	Text *text  `json:"text,omitempty"`
+}
+
+type text struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+func headerBlock(s string) block {
+	if r := []rune(s); len(r) > maxHeaderLen {
+		s = string(r[:maxHeaderLen-1]) + "…"
+	}
+	return block{Type: "header", Text: &text{Type: "plain_text", Text: s}}
+}
+
+func sectionBlock(s string) block {
+	return block{Type: "section", Text: &text{Type: "mrkdwn", Text: s}}
+}
+
+// markdownBlocks converts markdown to blocks, sections are split on line
+// boundaries to fit the length limit.
+func markdownBlocks(msg string) []block {
+	blocks := make([]block, 0)
+	section := strings.Builder{}
+	flush := func() {
+		if s := strings.TrimSpace(section.String()); len(s) != 0 {
+			blocks = append(blocks, sectionBlock(s))
+		}
+		section.Reset()
+	}
+	for _, line := range strings.Split(msg, "\n") {
+		if strings.HasPrefix(line, "## ") {
+			flush()
+			blocks = append(blocks, headerBlock(unescape(strings.TrimPrefix(line, "## "))))
+			continue
+		}
+		line = ToMrkdwn(line)
+		if section.Len()+len(line)+1 > maxSectionLen {
+			flush()
+		}
+		section.WriteString(line)
+		section.WriteString("\n")
+	}
+	flush()
+	return blocks
+}
+
+// ToMrkdwn converts a line of escaped markdown to slack mrkdwn. Mrkdwn has
+// no escapes, escaped formatting characters are kept literal by zero width
+// spaces around them.
+//
+//	[text](url) -> <url|text>
+//	**bold**    -> *bold*
+//	\*          -> \u200b*\u200b
+//
+// Source: https://api.slack.com/reference/surfaces/formatting
+func ToMrkdwn(line string) string {
+	b := strings.Builder{}
+	for i := 0; i < len(line); {
+		switch {
+		case line[i] == '\\' && i+1 < len(line):
+			b.WriteString(literal(line[i+1 : i+2]))
+			i += 2
+		case strings.HasPrefix(line[i:], "**"):
+			b.WriteByte('*')
+			i += 2
+		case line[i] == '[':
+			label, url, n := parseLink(line[i:])
+			if n == 0 {
+				b.WriteString(escape(line[i : i+1]))
+				i++
+				continue
+			}
+			b.WriteString(fmt.Sprintf("<%s|%s>", url, strings.ReplaceAll(literalText(label), "|", "¦")))
+			i += n
+		default:
+			b.WriteString(escape(line[i : i+1]))
+			i++
+		}
+	}
+	return b.String()
+}
+
+// parseLink parses a leading [label](url), n is 0 if it's not a link.
+func parseLink(s string) (label, url string, n int) {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case ']':
+			if i+1 >= len(s) || s[i+1] != '(' {
+				return "", "", 0
+			}
+			end := strings.IndexByte(s[i+2:], ')')
+			if end == -1 {
+				return "", "", 0
+			}
+			return s[1:i], s[i+2 : i+2+end], i + 2 + end + 1
+		}
+	}
+	return "", "", 0
+}
+
+// unescape removes markdown backslash escapes.
+func unescape(s string) string {
+	b := strings.Builder{}
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// zeroWidthSpace separates a formatting character from its neighbours, so
+// that it does not open or close formatting.
+const zeroWidthSpace = "\u200b"
+
+// literal returns the escaped character c that is kept literal in mrkdwn.
+func literal(c string) string {
+	if strings.Contains("*_~`", c) {
+		return zeroWidthSpace + c + zeroWidthSpace
+	}
+	return escape(c)
+}
+
+// literalText converts escaped markdown text, e.g. a link label, to mrkdwn
+// that keeps escaped characters literal.
+func literalText(s string) string {
+	b := strings.Builder{}
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			b.WriteString(literal(s[i+1 : i+2]))
+			i++
+			continue
+		}
+		b.WriteString(escape(s[i : i+1]))
+	}
+	return b.String()
+}
+
+// escape escapes slack control characters.
+var escape = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace
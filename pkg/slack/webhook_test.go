@@ -0,0 +1,68 @@
+// Copyright 2021 ghstats Project Authors. Licensed under MIT.
+
+package slack
+
+import (
+	"testing"
+)
+
+func TestToMrkdwn(t *testing.T) {
+	cases := []struct {
+		line   string
+		expect string
+	}{
+		{line: "plain text", expect: "plain text"},
+		{line: "**bold**", expect: "*bold*"},
+		{line: "[tidb](https://github.com/pingcap/tidb)", expect: "<https://github.com/pingcap/tidb|tidb>"},
+		{line: "- [\\#1](https://x/1) fix\\_bug", expect: "- <https://x/1|#1> fix\u200b_\u200bbug"},
+		// Escaped formatting characters are kept literal.
+		{line: "\\*not bold\\*", expect: "\u200b*\u200bnot bold\u200b*\u200b"},
+		{line: "\\_not italic\\_", expect: "\u200b_\u200bnot italic\u200b_\u200b"},
+		{line: "\\`not code\\`", expect: "\u200b`\u200bnot code\u200b`\u200b"},
+		{line: "[\\*x\\*](https://x)", expect: "<https://x|\u200b*\u200bx\u200b*\u200b>"},
+		// Other escapes are removed.
+		{line: "v1\\.0 \\(rc\\)\\!", expect: "v1.0 (rc)!"},
+		{line: "a\\\\b", expect: "a\\b"},
+		{line: "trailing\\", expect: "trailing\\"},
+		{line: "é\\é", expect: "éé"},
+		// Control characters are escaped.
+		{line: "a < b & c > d", expect: "a &lt; b &amp; c &gt; d"},
+		{line: "[a <b>](https://x)", expect: "<https://x|a &lt;b&gt;>"},
+		// Pipes end labels in mrkdwn.
+		{line: "[a|b](https://x)", expect: "<https://x|a¦b>"},
+		// Not links.
+		{line: "[a] (b)", expect: "[a] (b)"},
+		{line: "[a](b", expect: "[a](b"},
+	}
+	for _, c := range cases {
+		if got := ToMrkdwn(c.line); got != c.expect {
+			t.Errorf("%q: expect %q, got %q", c.line, c.expect, got)
+		}
+	}
+}
+
+func TestParseLink(t *testing.T) {
+	cases := []struct {
+		s     string
+		label string
+		url   string
+		n     int
+	}{
+		{s: "[a](https://x)", label: "a", url: "https://x", n: 14},
+		{s: "[a](https://x) rest", label: "a", url: "https://x", n: 14},
+		{s: "[a\\]b](u)", label: "a\\]b", url: "u", n: 9},
+		{s: "[\\[1\\]](u)", label: "\\[1\\]", url: "u", n: 10},
+		{s: "[](u)", label: "", url: "u", n: 5},
+		{s: "[a]", n: 0},
+		{s: "[a] (u)", n: 0},
+		{s: "[a](u", n: 0},
+		{s: "[a", n: 0},
+		{s: "[a\\]", n: 0},
+	}
+	for _, c := range cases {
+		label, url, n := parseLink(c.s)
+		if label != c.label || url != c.url || n != c.n {
+			t.Errorf("%q: expect %q %q %d, got %q %q %d", c.s, c.label, c.url, c.n, label, url, n)
+		}
+	}
+}
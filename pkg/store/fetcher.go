@@ -0,0 +1,252 @@
+// Copyright 2021 ghstats Project Authors. Licensed under MIT.
+
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v35/github"
+	"github.com/overvenus/ghstats/pkg/gh"
+	bolt "go.etcd.io/bbolt"
+)
+
+// perPage is the default page size of GitHub list APIs.
+const perPage = 30
+
+var _ gh.Fetcher = (*Store)(nil)
+
+// SearchIssueCursor is the cursor name of a synced search query.
+func SearchIssueCursor(query string) string {
+	return "search:" + query
+}
+
+// PullRequestsCursor is the cursor name of a synced repository pull list.
+func PullRequestsCursor(owner, repo string) string {
+	return fmt.Sprintf("pulls:%s/%s", owner, repo)
+}
+
+// SearchIssues returns issues matched by a synced query.
+//
+// Only a trailing `updated:<start>..<end>` qualifier is evaluated locally,
+// it matches issues that are created, closed, commented, triaged or
+// reviewed within the range, so that historical reports are still accurate after
+// an issue is updated again.
+func (s *Store) SearchIssues(
+	ctx context.Context, query string,
+) ([]*github.IssuesSearchResult, error) {
+	query, start, end, err := splitUpdatedRange(query)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok, err := s.Cursor(SearchIssueCursor(query)); err != nil {
+		return nil, err
+	} else if !ok {
+		return nil, fmt.Errorf("query %q has not been synced, run `gh sync` first", query)
+	}
+
+	issues := make([]*github.Issue, 0)
+	err = s.db.View(func(tx *bolt.Tx) error {
+		q := tx.Bucket(bucketQueries).Bucket([]byte(query))
+		return scan(q, nil, func(k, _ []byte) error {
+			var issue *github.Issue
+			if err := get(tx.Bucket(bucketIssues), k, &issue); err != nil {
+				return err
+			}
+			if issue == nil {
+				return nil
+			}
+			if start.IsZero() || activeWithin(tx, k, issue, start, end) {
+				issues = append(issues, issue)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	total := len(issues)
+	return []*github.IssuesSearchResult{{Total: &total, Issues: issues}}, nil
+}
+
+// splitUpdatedRange splits the trailing `updated:<start>..<end>` qualifier.
+func splitUpdatedRange(query string) (string, time.Time, time.Time, error) {
+	query = strings.TrimSpace(query)
+	idx := strings.LastIndex(query, " updated:")
+	if idx == -1 {
+		return query, time.Time{}, time.Time{}, nil
+	}
+	qualifier := strings.TrimPrefix(query[idx:], " updated:")
+	parts := strings.SplitN(qualifier, "..", 2)
+	if len(parts) != 2 {
+		return "", time.Time{}, time.Time{}, fmt.Errorf("unsupported qualifier updated:%s", qualifier)
+	}
+	start, err := time.Parse(time.RFC3339, parts[0])
+	if err != nil {
+		return "", time.Time{}, time.Time{}, err
+	}
+	end, err := time.Parse(time.RFC3339, parts[1])
+	if err != nil {
+		return "", time.Time{}, time.Time{}, err
+	}
+	return strings.TrimSpace(query[:idx]), start, end, nil
+}
+
+// Does the issue have any activity within [start, end]?
+func activeWithin(tx *bolt.Tx, key []byte, issue *github.Issue, start, end time.Time) bool {
+	within := func(ts *time.Time) bool {
+		return ts != nil && !ts.Before(start) && !ts.After(end)
+	}
+	if within(issue.CreatedAt) || within(issue.ClosedAt) {
+		return true
+	}
+	var comments []*github.IssueComment
+	if err := get(tx.Bucket(bucketComments), key, &comments); err == nil {
+		for _, comment := range comments {
+			if within(comment.CreatedAt) || within(comment.UpdatedAt) {
+				return true
+			}
+		}
+	}
+	var events []*github.IssueEvent
+	if err := get(tx.Bucket(bucketEvents), key, &events); err == nil {
+		for _, event := range events {
+			if within(event.CreatedAt) {
+				return true
+			}
+		}
+	}
+	var reviews []*github.PullRequestReview
+	if err := get(tx.Bucket(bucketReviews), key, &reviews); err == nil {
+		for _, review := range reviews {
+			if within(review.SubmittedAt) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// IssuesListComments returns synced comments updated since the given time.
+func (s *Store) IssuesListComments(
+	ctx context.Context, owner, repo string, number int, since *time.Time,
+) ([]*github.IssueComment, error) {
+	comments := make([]*github.IssueComment, 0)
+	if err := s.getValue(bucketComments, IssueKey(owner, repo, number), &comments); err != nil {
+		return nil, err
+	}
+	if since == nil {
+		return comments, nil
+	}
+	filtered := comments[:0]
+	for _, comment := range comments {
+		if comment.UpdatedAt != nil && !comment.UpdatedAt.Before(*since) {
+			filtered = append(filtered, comment)
+		}
+	}
+	return filtered, nil
+}
+
+// IssuesListIssueEvents returns synced events of an issue or a pull request.
+func (s *Store) IssuesListIssueEvents(
+	ctx context.Context, owner, repo string, number int,
+) ([]*github.IssueEvent, error) {
+	events := make([]*github.IssueEvent, 0)
+	err := s.getValue(bucketEvents, IssueKey(owner, repo, number), &events)
+	return events, err
+}
+
+// PullRequestsListReviews returns synced reviews of a pull request.
+func (s *Store) PullRequestsListReviews(
+	ctx context.Context, owner, repo string, number int,
+) ([]*github.PullRequestReview, error) {
+	reviews := make([]*github.PullRequestReview, 0)
+	err := s.getValue(bucketReviews, IssueKey(owner, repo, number), &reviews)
+	return reviews, err
+}
+
+// PullRequestsListReviewComments returns synced comments of a pull request review.
+func (s *Store) PullRequestsListReviewComments(
+	ctx context.Context, owner, repo string, number int, reviewID int64,
+) ([]*github.PullRequestComment, error) {
+	comments := make([]*github.PullRequestComment, 0)
+	key := fmt.Sprintf("%s/%d", IssueKey(owner, repo, number), reviewID)
+	err := s.getValue(bucketReviewComments, key, &comments)
+	return comments, err
+}
+
+// PullRequestsList returns synced pull requests of a repository, the most
+// recently created first.
+func (s *Store) PullRequestsList(
+	ctx context.Context, owner, repo string, maxPages int,
+) ([]*github.PullRequest, error) {
+	if _, ok, err := s.Cursor(PullRequestsCursor(owner, repo)); err != nil {
+		return nil, err
+	} else if !ok {
+		return nil, fmt.Errorf("pull requests of %s/%s have not been synced, run `gh sync` first", owner, repo)
+	}
+	prs := make([]*github.PullRequest, 0, perPage)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		prefix := []byte(fmt.Sprintf("%s/%s#", owner, repo))
+		return scan(tx.Bucket(bucketPulls), prefix, func(_, v []byte) error {
+			pr := &github.PullRequest{}
+			if err := json.Unmarshal(v, pr); err != nil {
+				return err
+			}
+			prs = append(prs, pr)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(prs, func(i, j int) bool {
+		return prs[i].GetCreatedAt().After(prs[j].GetCreatedAt())
+	})
+	if limit := maxPages * perPage; len(prs) > limit {
+		prs = prs[:limit]
+	}
+	return prs, nil
+}
+
+// PullRequestsListFiles returns synced changed files of a pull request.
+func (s *Store) PullRequestsListFiles(
+	ctx context.Context, owner, repo string, number int,
+) ([]*github.CommitFile, error) {
+	files := make([]*github.CommitFile, 0)
+	err := s.getValue(bucketFiles, IssueKey(owner, repo, number), &files)
+	return files, err
+}
+
+// PullRequestsGet returns a synced pull request.
+func (s *Store) PullRequestsGet(
+	ctx context.Context, owner, repo string, number int,
+) (*github.PullRequest, error) {
+	pr, err := s.PullRequest(owner, repo, number)
+	if err != nil {
+		return nil, err
+	}
+	if pr == nil {
+		return nil, fmt.Errorf("pull request %s has not been synced, run `gh sync` first", IssueKey(owner, repo, number))
+	}
+	return pr, nil
+}
+
+// RepositoriesGetFile is not supported, files of repositories are not
+// synced.
+func (s *Store) RepositoriesGetFile(
+	ctx context.Context, owner, repo, path, ref string,
+) (string, error) {
+	return "", fmt.Errorf("file %s of %s/%s is not synced, read it from GitHub instead", path, owner, repo)
+}
+
+// TeamsListMembers is not supported, teams are not synced.
+func (s *Store) TeamsListMembers(
+	ctx context.Context, org, slug string,
+) ([]*github.User, error) {
+	return nil, fmt.Errorf("team %s/%s is not synced, read it from GitHub instead", org, slug)
+}
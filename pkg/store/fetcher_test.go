@@ -0,0 +1,216 @@
+// Copyright 2021 ghstats Project Authors. Licensed under MIT.
+
+package store
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v35/github"
+)
+
+func openTestStore(t *testing.T) *Store {
+	s, err := Open(filepath.Join(t.TempDir(), "ghstats.db"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestSplitUpdatedRange(t *testing.T) {
+	start := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2021, 6, 8, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		query      string
+		expect     string
+		start, end time.Time
+		err        bool
+	}{
+		{query: "repo:o/r is:pr", expect: "repo:o/r is:pr"},
+		{query: "  repo:o/r is:pr\n", expect: "repo:o/r is:pr"},
+		{
+			query:  "repo:o/r is:pr updated:2021-06-01T00:00:00Z..2021-06-08T00:00:00Z",
+			expect: "repo:o/r is:pr", start: start, end: end,
+		},
+		{
+			query:  "\nrepo:o/r\n updated:2021-06-01T08:00:00+08:00..2021-06-08T08:00:00+08:00",
+			expect: "repo:o/r", start: start, end: end,
+		},
+		{query: "repo:o/r updated:>2021-06-01", err: true},
+		{query: "repo:o/r updated:2021-06-01..2021-06-08", err: true},
+	}
+	for _, c := range cases {
+		query, s, e, err := splitUpdatedRange(c.query)
+		if c.err {
+			if err == nil {
+				t.Errorf("%q: expect an error", c.query)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", c.query, err)
+			continue
+		}
+		if query != c.expect || !s.Equal(c.start) || !e.Equal(c.end) {
+			t.Errorf("%q: expect %q %s %s, got %q %s %s", c.query, c.expect, c.start, c.end, query, s, e)
+		}
+	}
+}
+
+func TestSearchIssuesWithinRange(t *testing.T) {
+	s := openTestStore(t)
+	ctx := context.Background()
+	const query = "repo:o/r is:pr"
+	start := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2021, 6, 8, 0, 0, 0, 0, time.UTC)
+	before, within, after := start.Add(-time.Hour), start.Add(time.Hour), end.Add(time.Hour)
+
+	put := func(number int, created time.Time, closed *time.Time) {
+		issue := &github.Issue{Number: github.Int(number), CreatedAt: &created, ClosedAt: closed}
+		if err := s.PutIssue(query, "o", "r", issue); err != nil {
+			t.Fatal(err)
+		}
+	}
+	must := func(err error) {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	put(1, before, nil)
+	put(2, within, nil)
+	put(3, before, &within)
+	put(4, before, nil)
+	must(s.PutIssueComments("o", "r", 4, []*github.IssueComment{{CreatedAt: &within}}))
+	put(5, before, nil)
+	must(s.PutIssueComments("o", "r", 5, []*github.IssueComment{{CreatedAt: &before, UpdatedAt: &within}}))
+	put(6, before, nil)
+	must(s.PutIssueEvents("o", "r", 6, []*github.IssueEvent{{CreatedAt: &within}}))
+	put(7, before, nil)
+	must(s.PutReviews("o", "r", 7, []*github.PullRequestReview{{SubmittedAt: &within}}))
+	// Activities out of the range.
+	put(8, before, &after)
+	must(s.PutIssueComments("o", "r", 8, []*github.IssueComment{{CreatedAt: &before, UpdatedAt: &before}}))
+	must(s.PutIssueEvents("o", "r", 8, []*github.IssueEvent{{CreatedAt: &after}}))
+	must(s.PutReviews("o", "r", 8, []*github.PullRequestReview{{SubmittedAt: &before}}))
+	// Bounds are inclusive.
+	put(9, start, nil)
+	put(10, before, &end)
+
+	if _, err := s.SearchIssues(ctx, query); err == nil || !strings.Contains(err.Error(), "has not been synced") {
+		t.Fatalf("expect a not synced error, got %v", err)
+	}
+	must(s.SetCursor(SearchIssueCursor(query), end))
+
+	numbers := func(q string) []int {
+		results, err := s.SearchIssues(ctx, q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ns := make([]int, 0)
+		for _, res := range results {
+			for _, issue := range res.Issues {
+				ns = append(ns, issue.GetNumber())
+			}
+		}
+		sort.Ints(ns)
+		return ns
+	}
+	all := []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	if ns := numbers(query); !reflect.DeepEqual(ns, all) {
+		t.Errorf("expect %v without a range, got %v", all, ns)
+	}
+	q := query + " updated:" + start.Format(time.RFC3339) + ".." + end.Format(time.RFC3339)
+	if ns, expect := numbers(q), []int{2, 3, 4, 5, 6, 7, 9, 10}; !reflect.DeepEqual(ns, expect) {
+		t.Errorf("expect %v within the range, got %v", expect, ns)
+	}
+	// Issues of other queries are not matched.
+	must(s.SetCursor(SearchIssueCursor("repo:o/other"), end))
+	if ns := numbers("repo:o/other"); len(ns) != 0 {
+		t.Errorf("expect no issues of another query, got %v", ns)
+	}
+}
+
+func TestPullRequestsList(t *testing.T) {
+	s := openTestStore(t)
+	ctx := context.Background()
+	if _, err := s.PullRequestsList(ctx, "o", "r", 1); err == nil || !strings.Contains(err.Error(), "have not been synced") {
+		t.Fatalf("expect a not synced error, got %v", err)
+	}
+	if _, err := s.PullRequestsGet(ctx, "o", "r", 1); err == nil || !strings.Contains(err.Error(), "has not been synced") {
+		t.Fatalf("expect a not synced error, got %v", err)
+	}
+
+	base := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	// Numbers are not in creation order, keys are not either, e.g. #10 is
+	// before #9. Every PR is created at a distinct hour in [0, total).
+	total := perPage + 5
+	for i := 1; i <= total; i++ {
+		created := base.Add(time.Duration((i*11)%total) * time.Hour)
+		pr := &github.PullRequest{Number: github.Int(i), CreatedAt: &created}
+		if err := s.PutPullRequest("o", "r", pr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Pull requests of other repositories that share the prefix, it would be
+	// the latest if it were listed.
+	created := base.Add(1000 * time.Hour)
+	if err := s.PutPullRequest("o", "r2", &github.PullRequest{Number: github.Int(1), CreatedAt: &created}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetCursor(PullRequestsCursor("o", "r"), base); err != nil {
+		t.Fatal(err)
+	}
+
+	prs, err := s.PullRequestsList(ctx, "o", "r", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(prs) != perPage {
+		t.Fatalf("expect %d pull requests of one page, got %d", perPage, len(prs))
+	}
+	for i := 1; i < len(prs); i++ {
+		if prs[i].GetCreatedAt().After(prs[i-1].GetCreatedAt()) {
+			t.Fatalf("expect the most recently created first, got #%d before #%d", prs[i-1].GetNumber(), prs[i].GetNumber())
+		}
+	}
+	if latest := base.Add(time.Duration(total-1) * time.Hour); !prs[0].GetCreatedAt().Equal(latest) {
+		t.Errorf("expect the latest pull request first, got %s", prs[0].GetCreatedAt())
+	}
+
+	prs, err = s.PullRequestsList(ctx, "o", "r", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(prs) != total {
+		t.Errorf("expect all %d pull requests, got %d", total, len(prs))
+	}
+
+	pr, err := s.PullRequestsGet(ctx, "o", "r", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pr.GetNumber() != 3 {
+		t.Errorf("expect #3, got #%d", pr.GetNumber())
+	}
+}
+
+func TestCursor(t *testing.T) {
+	s := openTestStore(t)
+	if _, ok, err := s.Cursor("search:q"); err != nil || ok {
+		t.Fatalf("expect no cursor, got %v %v", ok, err)
+	}
+	ts := time.Date(2021, 6, 1, 8, 0, 0, 0, time.UTC)
+	if err := s.SetCursor("search:q", ts); err != nil {
+		t.Fatal(err)
+	}
+	got, ok, err := s.Cursor("search:q")
+	if err != nil || !ok || !got.Equal(ts) {
+		t.Errorf("expect %s, got %s %v %v", ts, got, ok, err)
+	}
+}
@@ -0,0 +1,216 @@
+// Copyright 2021 ghstats Project Authors. Licensed under MIT.
+
+package store
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/go-github/v35/github"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	bucketIssues         = []byte("issues")
+	bucketQueries        = []byte("queries")
+	bucketPulls          = []byte("pulls")
+	bucketReviews        = []byte("reviews")
+	bucketReviewComments = []byte("review-comments")
+	bucketComments       = []byte("comments")
+	bucketEvents         = []byte("events")
+	bucketFiles          = []byte("files")
+	bucketCursors        = []byte("cursors")
+
+	buckets = [][]byte{
+		bucketIssues, bucketQueries, bucketPulls, bucketReviews,
+		bucketReviewComments, bucketComments, bucketEvents, bucketFiles, bucketCursors,
+	}
+)
+
+// Store is a local database of GitHub issues, pull requests and
+// their reviews and comments, filled by `gh sync`.
+//
+// Layout, all values are JSON encoded go-github structs:
+//
+//	issues/<owner>/<repo>#<number>                 Issue
+//	queries/<query>/<owner>/<repo>#<number>        (empty), issues matched by a query
+//	pulls/<owner>/<repo>#<number>                  PullRequest
+//	reviews/<owner>/<repo>#<number>                []PullRequestReview
+//	review-comments/<owner>/<repo>#<number>/<id>   []PullRequestComment
+//	comments/<owner>/<repo>#<number>               []IssueComment
+//	events/<owner>/<repo>#<number>                 []IssueEvent
+//	files/<owner>/<repo>#<number>                  []CommitFile
+//	cursors/<name>                                 RFC3339 time of the last sync
+type Store struct {
+	db *bolt.DB
+}
+
+// Open opens or creates the store at the given path.
+func Open(path string, readOnly bool) (*Store, error) {
+	db, err := bolt.Open(path, 0o666, &bolt.Options{Timeout: 3 * time.Second, ReadOnly: readOnly})
+	if err != nil {
+		return nil, fmt.Errorf("open store %s: %w", path, err)
+	}
+	if !readOnly {
+		err = db.Update(func(tx *bolt.Tx) error {
+			for _, name := range buckets {
+				if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+	return &Store{db: db}, nil
+}
+
+// Close closes the store.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// IssueKey returns the store key of an issue or a pull request.
+func IssueKey(owner, repo string, number int) string {
+	return fmt.Sprintf("%s/%s#%d", owner, repo, number)
+}
+
+// Cursor returns the time of the last successful sync of name.
+func (s *Store) Cursor(name string) (ts time.Time, ok bool, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketCursors)
+		if b == nil {
+			return nil
+		}
+		v := b.Get([]byte(name))
+		if v == nil {
+			return nil
+		}
+		ok = true
+		ts, err = time.Parse(time.RFC3339, string(v))
+		return err
+	})
+	return
+}
+
+// SetCursor records the time of the last successful sync of name.
+func (s *Store) SetCursor(name string, ts time.Time) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketCursors).Put([]byte(name), []byte(ts.Format(time.RFC3339)))
+	})
+}
+
+// PutIssue saves an issue and records that it matches the query.
+func (s *Store) PutIssue(query string, owner, repo string, issue *github.Issue) error {
+	key := []byte(IssueKey(owner, repo, issue.GetNumber()))
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if err := put(tx.Bucket(bucketIssues), key, issue); err != nil {
+			return err
+		}
+		q, err := tx.Bucket(bucketQueries).CreateBucketIfNotExists([]byte(query))
+		if err != nil {
+			return err
+		}
+		return q.Put(key, []byte{})
+	})
+}
+
+// PutPullRequest saves a pull request.
+func (s *Store) PutPullRequest(owner, repo string, pr *github.PullRequest) error {
+	return s.putValue(bucketPulls, IssueKey(owner, repo, pr.GetNumber()), pr)
+}
+
+// PullRequest returns a saved pull request, or nil if it is not found.
+func (s *Store) PullRequest(owner, repo string, number int) (*github.PullRequest, error) {
+	var pr *github.PullRequest
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return get(tx.Bucket(bucketPulls), []byte(IssueKey(owner, repo, number)), &pr)
+	})
+	return pr, err
+}
+
+// PutReviews saves all reviews of a pull request.
+func (s *Store) PutReviews(
+	owner, repo string, number int, reviews []*github.PullRequestReview,
+) error {
+	return s.putValue(bucketReviews, IssueKey(owner, repo, number), reviews)
+}
+
+// PutReviewComments saves all comments of a pull request review.
+func (s *Store) PutReviewComments(
+	owner, repo string, number int, reviewID int64, comments []*github.PullRequestComment,
+) error {
+	key := fmt.Sprintf("%s/%d", IssueKey(owner, repo, number), reviewID)
+	return s.putValue(bucketReviewComments, key, comments)
+}
+
+// PutIssueComments saves all comments of an issue or a pull request.
+func (s *Store) PutIssueComments(
+	owner, repo string, number int, comments []*github.IssueComment,
+) error {
+	return s.putValue(bucketComments, IssueKey(owner, repo, number), comments)
+}
+
+// PutIssueEvents saves all events of an issue or a pull request.
+func (s *Store) PutIssueEvents(
+	owner, repo string, number int, events []*github.IssueEvent,
+) error {
+	return s.putValue(bucketEvents, IssueKey(owner, repo, number), events)
+}
+
+// PutFiles saves all changed files of a pull request.
+func (s *Store) PutFiles(owner, repo string, number int, files []*github.CommitFile) error {
+	return s.putValue(bucketFiles, IssueKey(owner, repo, number), files)
+}
+
+func (s *Store) putValue(bucket []byte, key string, value interface{}) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return put(tx.Bucket(bucket), []byte(key), value)
+	})
+}
+
+func (s *Store) getValue(bucket []byte, key string, value interface{}) error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		return get(tx.Bucket(bucket), []byte(key), value)
+	})
+}
+
+func put(b *bolt.Bucket, key []byte, value interface{}) error {
+	v, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return b.Put(key, v)
+}
+
+// get decodes the value of key into value, value is untouched if the key
+// does not exist.
+func get(b *bolt.Bucket, key []byte, value interface{}) error {
+	if b == nil {
+		return nil
+	}
+	v := b.Get(key)
+	if v == nil {
+		return nil
+	}
+	return json.Unmarshal(v, value)
+}
+
+// scan calls fn for each key with the given prefix.
+func scan(b *bolt.Bucket, prefix []byte, fn func(k, v []byte) error) error {
+	if b == nil {
+		return nil
+	}
+	c := b.Cursor()
+	for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+		if err := fn(k, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}